@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"chicken-farm/internal/config"
+	"chicken-farm/internal/migration"
+	"chicken-farm/internal/passport"
+	"chicken-farm/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+const encryptPassportsUsage = "usage: chicken-farm [flags] encrypt-passports"
+
+// runEncryptPassports шифрует паспорта, сохраненные открытым текстом до появления шифрования.
+// Повторный запуск ничего не меняет.
+func runEncryptPassports(db *gorm.DB, cfg config.SecurityConfig, out io.Writer) error {
+	passports, err := newPassportCipher(cfg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := migration.NewMigrator(db).Up(); err != nil {
+		return err
+	}
+
+	count, err := repository.NewEmployeeRepository(db, passports).EncryptPassports()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "encrypted %d passports\n", count)
+	return nil
+}
+
+func newPassportCipher(cfg config.SecurityConfig) (*passport.Cipher, error) {
+	if cfg.PassportKey == "" {
+		return nil, errors.New("security.passport_key is not set (" + config.EnvPassportKey + "), " +
+			"generate one with: openssl rand -base64 32")
+	}
+
+	key, err := passport.ParseKey(cfg.PassportKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return passport.NewCipher(key)
+}
@@ -1,65 +1,143 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
 
+	"chicken-farm/internal/config"
 	"chicken-farm/internal/controller"
+	"chicken-farm/internal/database"
+	"chicken-farm/internal/migration"
 	"chicken-farm/internal/model"
 	"chicken-farm/internal/repository"
 	"chicken-farm/internal/service"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func main() {
-	db, err := initDB()
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	db, err := database.Open(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	if err := migrateDB(db); err != nil {
-		log.Fatal("Failed to migrate database:", err)
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			err = runMigrate(db, args[1:], os.Stdout)
+		case "encrypt-passports":
+			err = runEncryptPassports(db, cfg.Security, os.Stdout)
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q\n%s\n%s\n", args[0], migrateUsage, encryptPassportsUsage)
+			os.Exit(2)
+		}
+
+		if err != nil {
+			log.Fatalf("%s failed: %v", args[0], err)
+		}
+		return
 	}
 
-	if err := seedData(db); err != nil {
-		log.Fatal("Failed to seed data:", err)
+	passports, err := newPassportCipher(cfg.Security)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := migration.NewMigrator(db).Up(); err != nil {
+		log.Fatal("Failed to migrate database:", err)
 	}
 
 	chickenRepo := repository.NewChickenRepository(db)
-	employeeRepo := repository.NewEmployeeRepository(db)
+	employeeRepo := repository.NewEmployeeRepository(db, passports)
 	farmRepo := repository.NewFarmRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	payrollRepo := repository.NewPayrollRepository(db)
+	shiftRepo := repository.NewShiftRepository(db)
+
+	// паспорта, сохраненные до появления шифрования, шифруются до приема запросов:
+	// без хэша дубликат такого паспорта не обнаружить
+	if count, err := employeeRepo.EncryptPassports(); err != nil {
+		log.Fatal("Failed to encrypt passports:", err)
+	} else if count > 0 {
+		log.Printf("Encrypted %d passports saved by an earlier version", count)
+	}
+
+	if err := seedData(db, chickenRepo, employeeRepo); err != nil {
+		log.Fatal("Failed to seed data:", err)
+	}
 
 	chickenService := service.NewChickenService(chickenRepo, farmRepo)
 	employeeService := service.NewEmployeeService(employeeRepo, farmRepo)
-	reportService := service.NewReportService(chickenRepo, employeeRepo, farmRepo)
+	reportService := service.NewReportService(chickenRepo, employeeRepo, farmRepo, shiftRepo)
+	farmRecordService := service.NewFarmRecordService(farmRepo, chickenRepo, employeeRepo, shiftRepo)
+	collectionSheetService := service.NewCollectionSheetService(chickenRepo, farmRepo, employeeRepo, shiftRepo)
+	cageService := service.NewCageService(farmRepo, chickenRepo, employeeRepo)
+	configService := service.NewConfigService(farmRepo)
+	eggPriceService := service.NewEggPriceService(farmRepo)
+	authService := service.NewAuthService(userRepo, employeeRepo, cfg.Auth.SessionTTL)
+	userService := service.NewUserService(userRepo, employeeRepo)
+	workloadService := service.NewWorkloadService(employeeRepo, farmRepo)
+	payrollService := service.NewPayrollService(payrollRepo, employeeRepo, farmRepo, shiftRepo)
+	shiftService := service.NewShiftService(shiftRepo, employeeRepo)
+
+	created, err := authService.EnsureAdmin(cfg.Auth.AdminUsername, cfg.Auth.AdminPassword)
+	if err != nil {
+		log.Fatal("Failed to create admin user:", err)
+	}
+	if created {
+		log.Printf("Created admin user %q", cfg.Auth.AdminUsername)
+	}
 
 	chickenController := controller.NewChickenController(chickenService)
 	employeeController := controller.NewEmployeeController(employeeService)
 	reportController := controller.NewReportController(reportService)
+	farmRecordController := controller.NewFarmRecordController(farmRecordService)
+	collectionSheetController := controller.NewCollectionSheetController(collectionSheetService)
+	cageController := controller.NewCageController(cageService)
+	configController := controller.NewConfigController(configService)
+	eggPriceController := controller.NewEggPriceController(eggPriceService)
+	authController := controller.NewAuthController(authService)
+	userController := controller.NewUserController(userService)
+	workloadController := controller.NewWorkloadController(workloadService)
+	payrollController := controller.NewPayrollController(payrollService)
+	shiftController := controller.NewShiftController(shiftService)
 
 	router := gin.Default()
 
-	config := cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:3000"},
+	corsConfig := cors.Config{
+		AllowOrigins:     cfg.CORS.AllowOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "X-Total-Count", "Link"},
 		AllowCredentials: true,
 		MaxAge:           12 * 3600, // 12 часов
 	}
-	router.Use(cors.New(config))
+	router.Use(cors.New(corsConfig))
 
 	router.Use(func(c *gin.Context) {
 		log.Printf("Method: %s, Path: %s, Origin: %s", c.Request.Method, c.Request.URL.Path, c.Request.Header.Get("Origin"))
 		c.Next()
 	})
 
+	router.Use(controller.Authenticate(authService))
+
 	router.OPTIONS("/api/*path", func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "http://localhost:3000")
+		if origin := c.Request.Header.Get("Origin"); slices.Contains(cfg.CORS.AllowOrigins, origin) {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With")
 		c.Header("Access-Control-Allow-Credentials", "true")
@@ -69,42 +147,35 @@ func main() {
 	chickenController.RegisterRoutes(router)
 	employeeController.RegisterRoutes(router)
 	reportController.RegisterRoutes(router)
-
-	router.Static("/static", "./web/build/static")
-	router.StaticFile("/", "./web/build/index.html")
+	farmRecordController.RegisterRoutes(router)
+	collectionSheetController.RegisterRoutes(router)
+	cageController.RegisterRoutes(router)
+	configController.RegisterRoutes(router)
+	eggPriceController.RegisterRoutes(router)
+	authController.RegisterRoutes(router)
+	userController.RegisterRoutes(router)
+	workloadController.RegisterRoutes(router)
+	payrollController.RegisterRoutes(router)
+	shiftController.RegisterRoutes(router)
+
+	if _, err := os.Stat(cfg.Server.StaticDir); err != nil {
+		log.Printf("Static directory %s is not available, frontend will not be served: %v", cfg.Server.StaticDir, err)
+	}
+	router.Static("/static", filepath.Join(cfg.Server.StaticDir, "static"))
+	router.StaticFile("/", filepath.Join(cfg.Server.StaticDir, "index.html"))
 
 	router.GET("/api/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
-	log.Println("Server starting on :8080")
-	if err := router.Run(":8080"); err != nil {
+	log.Printf("Server starting on %s", cfg.Server.Addr)
+	if err := router.Run(cfg.Server.Addr); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
 
-func initDB() (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open("chicken_farm.db"), &gorm.Config{})
-	if err != nil {
-		return nil, err
-	}
-
-	return db, nil
-}
-
-func migrateDB(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&model.Chicken{},
-		&model.Employee{},
-		&model.EmployeeCage{},
-		&model.Farm{},
-		&model.Cage{},
-		&model.ConfigParam{},
-	)
-}
-
 // начальные данные
-func seedData(db *gorm.DB) error {
+func seedData(db *gorm.DB, chickenRepo *repository.ChickenRepository, employeeRepo *repository.EmployeeRepository) error {
 	var cageCount int64
 	if err := db.Model(&model.Cage{}).Count(&cageCount).Error; err != nil {
 		return err
@@ -128,45 +199,35 @@ func seedData(db *gorm.DB) error {
 		}
 	}
 
+	// куры и закрепления начальных сотрудников появляются в день заполнения базы
+	now := time.Now()
+	year, month, day := now.Date()
+	since := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
 	chickens := []model.Chicken{
-		{CageID: 1, Weight: 2.5, Age: 12, EggPerMonth: 25, Breed: "Леггорн"},
-		{CageID: 2, Weight: 3.0, Age: 18, EggPerMonth: 22, Breed: "Род-Айленд"},
-		{CageID: 3, Weight: 2.8, Age: 15, EggPerMonth: 28, Breed: "Нью-Гемпшир"},
+		{CageID: 1, Weight: 2.5, HatchDate: model.NewDate(model.HatchDateForAge(12, now)), EggPerMonth: 25, Breed: "Леггорн"},
+		{CageID: 2, Weight: 3.0, HatchDate: model.NewDate(model.HatchDateForAge(18, now)), EggPerMonth: 22, Breed: "Род-Айленд"},
+		{CageID: 3, Weight: 2.8, HatchDate: model.NewDate(model.HatchDateForAge(15, now)), EggPerMonth: 28, Breed: "Нью-Гемпшир"},
 	}
 
 	for _, chicken := range chickens {
-		if err := db.Create(&chicken).Error; err != nil {
+		if err := chickenRepo.Create(&chicken, since); err != nil {
 			return err
 		}
 	}
 
 	employees := []model.Employee{
-		{FullName: "Иванов Иван Иванович", PassportData: "1234 567890", Salary: 50000},
-		{FullName: "Петров Петр Петрович", PassportData: "2345 678901", Salary: 45000},
+		{FullName: "Иванов Иван Иванович", PassportData: "1234 567890", Salary: 50000, Cages: []uint{1, 2}},
+		{FullName: "Петров Петр Петрович", PassportData: "2345 678901", Salary: 45000, Cages: []uint{3, 4, 5}},
 	}
 
 	for _, employee := range employees {
-		if err := db.Create(&employee).Error; err != nil {
-			return err
-		}
-	}
-
-	employeeCages := []model.EmployeeCage{
-		{EmployeeID: 1, CageID: 1},
-		{EmployeeID: 1, CageID: 2},
-		{EmployeeID: 2, CageID: 3},
-		{EmployeeID: 2, CageID: 4},
-		{EmployeeID: 2, CageID: 5},
-	}
-
-	for _, ec := range employeeCages {
-		if err := db.Create(&ec).Error; err != nil {
+		if err := employeeRepo.Create(&employee, since, false); err != nil {
 			return err
 		}
 	}
 
 	eggPrice := model.ConfigParam{
-		Key:   "egg_price",
+		Key:   model.ConfigEggPrice,
 		Value: "10.0",
 	}
 
@@ -2,66 +2,173 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"chicken-farm/internal/config"
 	"chicken-farm/internal/controller"
+	"chicken-farm/internal/database"
+	"chicken-farm/internal/migration"
 	"chicken-farm/internal/model"
+	"chicken-farm/internal/passport"
 	"chicken-farm/internal/repository"
 	"chicken-farm/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 type TestSuite struct {
 	suite.Suite
-	db                 *gorm.DB
-	router             *gin.Engine
-	chickenController  *controller.ChickenController
-	employeeController *controller.EmployeeController
-	reportController   *controller.ReportController
+	dbConfig                  config.DatabaseConfig
+	db                        *gorm.DB
+	router                    *authorizedRouter
+	chickenController         *controller.ChickenController
+	employeeController        *controller.EmployeeController
+	reportController          *controller.ReportController
+	farmRecordController      *controller.FarmRecordController
+	collectionSheetController *controller.CollectionSheetController
+	cageController            *controller.CageController
+	configController          *controller.ConfigController
+	eggPriceController        *controller.EggPriceController
+	authController            *controller.AuthController
+	userController            *controller.UserController
+	workloadController        *controller.WorkloadController
+	payrollController         *controller.PayrollController
+	shiftController           *controller.ShiftController
+	authService               *service.AuthService
+	passports                 *passport.Cipher
+}
+
+// seedAssignedFrom - начало закреплений клеток у сотрудников из тестовых данных,
+// раньше любых записей о яйцах в тестах
+var seedAssignedFrom = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testPassportKey - ключ шифрования паспортов в тестах
+var testPassportKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, passport.KeySize))
+
+func newTestPassportCipher(tb testing.TB) *passport.Cipher {
+	key, err := passport.ParseKey(testPassportKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	passports, err := passport.NewCipher(key)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return passports
+}
+
+// authorizedRouter подставляет токен администратора в запросы без заголовка Authorization,
+// чтобы сценарии, не связанные с правами доступа, не занимались входом
+type authorizedRouter struct {
+	*gin.Engine
+	token string
+}
+
+func (r *authorizedRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	r.Engine.ServeHTTP(w, req)
+}
+
+// loginAdmin создает администратора и возвращает сервис авторизации и токен его сессии
+func loginAdmin(tb testing.TB, db *gorm.DB) (*service.AuthService, string) {
+	userRepo := repository.NewUserRepository(db)
+	authService := service.NewAuthService(userRepo, repository.NewEmployeeRepository(db, newTestPassportCipher(tb)), time.Hour)
+
+	admin := &model.User{Username: "admin", PasswordHash: "-", Role: model.RoleAdmin}
+	if err := userRepo.Create(admin); err != nil {
+		tb.Fatal(err)
+	}
+
+	session, err := authService.StartSession(admin)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return authService, session.Token
 }
 
 func (suite *TestSuite) SetupTest() {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db, err := database.Open(suite.dbConfig)
 	suite.Require().NoError(err)
 
-	err = db.AutoMigrate(
-		&model.Chicken{},
-		&model.Employee{},
-		&model.EmployeeCage{},
-		&model.Farm{},
-		&model.Cage{},
-		&model.ConfigParam{},
-	)
+	if suite.dbConfig.Driver == config.DriverPostgres {
+		// каждый тест начинается с пустой схемы, чтобы идентификаторы снова шли с 1
+		suite.Require().NoError(db.Exec("DROP SCHEMA public CASCADE").Error)
+		suite.Require().NoError(db.Exec("CREATE SCHEMA public").Error)
+	}
+
+	_, err = migration.NewMigrator(db).Up()
 	suite.Require().NoError(err)
 
 	suite.db = db
 
 	chickenRepo := repository.NewChickenRepository(db)
-	employeeRepo := repository.NewEmployeeRepository(db)
+	suite.passports = newTestPassportCipher(suite.T())
+	employeeRepo := repository.NewEmployeeRepository(db, suite.passports)
 	farmRepo := repository.NewFarmRepository(db)
+	shiftRepo := repository.NewShiftRepository(db)
 
 	chickenService := service.NewChickenService(chickenRepo, farmRepo)
 	employeeService := service.NewEmployeeService(employeeRepo, farmRepo)
-	reportService := service.NewReportService(chickenRepo, employeeRepo, farmRepo)
+	reportService := service.NewReportService(chickenRepo, employeeRepo, farmRepo, shiftRepo)
+	farmRecordService := service.NewFarmRecordService(farmRepo, chickenRepo, employeeRepo, shiftRepo)
+	collectionSheetService := service.NewCollectionSheetService(chickenRepo, farmRepo, employeeRepo, shiftRepo)
+	cageService := service.NewCageService(farmRepo, chickenRepo, employeeRepo)
+	configService := service.NewConfigService(farmRepo)
+	eggPriceService := service.NewEggPriceService(farmRepo)
+	authService, adminToken := loginAdmin(suite.T(), db)
+	userService := service.NewUserService(repository.NewUserRepository(db), employeeRepo)
+	suite.authService = authService
 
 	suite.chickenController = controller.NewChickenController(chickenService)
 	suite.employeeController = controller.NewEmployeeController(employeeService)
 	suite.reportController = controller.NewReportController(reportService)
+	suite.farmRecordController = controller.NewFarmRecordController(farmRecordService)
+	suite.collectionSheetController = controller.NewCollectionSheetController(collectionSheetService)
+	suite.cageController = controller.NewCageController(cageService)
+	suite.configController = controller.NewConfigController(configService)
+	suite.eggPriceController = controller.NewEggPriceController(eggPriceService)
+	suite.authController = controller.NewAuthController(authService)
+	suite.userController = controller.NewUserController(userService)
+	suite.workloadController = controller.NewWorkloadController(service.NewWorkloadService(employeeRepo, farmRepo))
+	suite.payrollController = controller.NewPayrollController(
+		service.NewPayrollService(repository.NewPayrollRepository(db), employeeRepo, farmRepo, shiftRepo))
+	suite.shiftController = controller.NewShiftController(service.NewShiftService(shiftRepo, employeeRepo))
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(controller.Authenticate(authService))
 	suite.chickenController.RegisterRoutes(router)
 	suite.employeeController.RegisterRoutes(router)
 	suite.reportController.RegisterRoutes(router)
-	suite.router = router
+	suite.farmRecordController.RegisterRoutes(router)
+	suite.collectionSheetController.RegisterRoutes(router)
+	suite.cageController.RegisterRoutes(router)
+	suite.configController.RegisterRoutes(router)
+	suite.eggPriceController.RegisterRoutes(router)
+	suite.authController.RegisterRoutes(router)
+	suite.userController.RegisterRoutes(router)
+	suite.workloadController.RegisterRoutes(router)
+	suite.payrollController.RegisterRoutes(router)
+	suite.shiftController.RegisterRoutes(router)
+	suite.router = &authorizedRouter{Engine: router, token: adminToken}
 
 	suite.seedTestData()
 }
@@ -77,27 +184,21 @@ func (suite *TestSuite) seedTestData() {
 	}
 
 	chickens := []model.Chicken{
-		{CageID: 1, Weight: 2.5, Age: 12, EggPerMonth: 25, Breed: "Леггорн"},
-		{CageID: 2, Weight: 3.0, Age: 18, EggPerMonth: 22, Breed: "Род-Айленд"},
+		{CageID: 1, Weight: 2.5, HatchDate: model.NewDate(model.HatchDateForAge(12, time.Now())), EggPerMonth: 25, Breed: "Леггорн"},
+		{CageID: 2, Weight: 3.0, HatchDate: model.NewDate(model.HatchDateForAge(18, time.Now())), EggPerMonth: 22, Breed: "Род-Айленд"},
 	}
 	for _, chicken := range chickens {
 		suite.db.Create(&chicken)
+		suite.db.Create(&model.ChickenCage{ChickenID: chicken.ID, CageID: chicken.CageID, ValidFrom: seedAssignedFrom})
 	}
 
 	employees := []model.Employee{
-		{FullName: "Иванов Иван Иванович", PassportData: "1234 567890", Salary: 50000},
-		{FullName: "Петров Петр Петрович", PassportData: "2345 678901", Salary: 45000},
+		{FullName: "Иванов Иван Иванович", PassportData: "1234 567890", Salary: 50000, Cages: []uint{1}, CreatedAt: seedAssignedFrom},
+		{FullName: "Петров Петр Петрович", PassportData: "2345 678901", Salary: 45000, Cages: []uint{2}, CreatedAt: seedAssignedFrom},
 	}
+	employeeRepo := repository.NewEmployeeRepository(suite.db, suite.passports)
 	for _, employee := range employees {
-		suite.db.Create(&employee)
-	}
-
-	employeeCages := []model.EmployeeCage{
-		{EmployeeID: 1, CageID: 1},
-		{EmployeeID: 2, CageID: 2},
-	}
-	for _, ec := range employeeCages {
-		suite.db.Create(&ec)
+		employeeRepo.Create(&employee, seedAssignedFrom, false)
 	}
 }
 
@@ -114,6 +215,74 @@ func (suite *TestSuite) TestGetAllChickens() {
 	assert.Len(suite.T(), chickens, 2)
 }
 
+func (suite *TestSuite) TestListChickensAndEmployees() {
+	for i, weight := range []float64{1.8, 2.2, 3.4} {
+		cage := model.Cage{Number: 10 + i}
+		suite.db.Create(&cage)
+		suite.db.Create(&model.Chicken{CageID: cage.ID, Weight: weight, HatchDate: model.NewDate(model.HatchDateForAge(6, time.Now())), EggPerMonth: 20, Breed: "Леггорн"})
+	}
+
+	// без page и per_page список отдается целиком
+	w := suite.sendJSON("GET", "/api/chickens?sort=-weight", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Equal(suite.T(), "5", w.Header().Get("X-Total-Count"))
+	assert.Empty(suite.T(), w.Header().Get("Link"))
+	var chickens []model.Chicken
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &chickens))
+	assert.Len(suite.T(), chickens, 5)
+
+	w = suite.sendJSON("GET", "/api/chickens?per_page=2&sort=-weight", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Equal(suite.T(), "5", w.Header().Get("X-Total-Count"))
+	assert.Contains(suite.T(), w.Header().Get("Link"), `page=2`)
+	assert.Contains(suite.T(), w.Header().Get("Link"), `rel="next"`)
+
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &chickens))
+	suite.Require().Len(chickens, 2)
+	assert.Equal(suite.T(), 3.4, chickens[0].Weight)
+	assert.Equal(suite.T(), 3.0, chickens[1].Weight)
+
+	// последняя страница: ссылки на следующую нет
+	w = suite.sendJSON("GET", "/api/chickens?per_page=2&page=3&sort=-weight", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &chickens))
+	suite.Require().Len(chickens, 1)
+	assert.Equal(suite.T(), 1.8, chickens[0].Weight)
+	assert.NotContains(suite.T(), w.Header().Get("Link"), `rel="next"`)
+	assert.Contains(suite.T(), w.Header().Get("Link"), `rel="prev"`)
+
+	w = suite.sendJSON("GET", "/api/chickens?breed=Леггорн&min_weight=2&max_weight=3&sort=breed,-weight", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Equal(suite.T(), "2", w.Header().Get("X-Total-Count"))
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &chickens))
+	suite.Require().Len(chickens, 2)
+	assert.Equal(suite.T(), 2.5, chickens[0].Weight)
+	assert.Equal(suite.T(), 2.2, chickens[1].Weight)
+
+	w = suite.sendJSON("GET", "/api/chickens?cage_id=2", "")
+	assert.Equal(suite.T(), "1", w.Header().Get("X-Total-Count"))
+
+	w = suite.sendJSON("GET", "/api/chickens?sort=passport", "")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.sendJSON("GET", "/api/chickens?per_page=1000", "")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.sendJSON("GET", "/api/chickens?min_weight=heavy", "")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	w = suite.sendJSON("GET", "/api/employees?min_salary=46000", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Equal(suite.T(), "1", w.Header().Get("X-Total-Count"))
+	var employees []model.Employee
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &employees))
+	suite.Require().Len(employees, 1)
+	assert.Equal(suite.T(), []uint{1}, employees[0].Cages)
+
+	w = suite.sendJSON("GET", "/api/employees?sort=-salary&per_page=1&page=2", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &employees))
+	suite.Require().Len(employees, 1)
+	assert.Equal(suite.T(), 45000.0, employees[0].Salary)
+}
+
 func (suite *TestSuite) TestGetChickenByID() {
 	req, _ := http.NewRequest("GET", "/api/chickens/1", nil)
 	w := httptest.NewRecorder()
@@ -167,7 +336,7 @@ func (suite *TestSuite) TestCreateChickenInvalidCage() {
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	assert.Equal(suite.T(), http.StatusInternalServerError, w.Code)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
 }
 
 func (suite *TestSuite) TestCreateChickenOccupiedCage() {
@@ -185,7 +354,7 @@ func (suite *TestSuite) TestCreateChickenOccupiedCage() {
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	assert.Equal(suite.T(), http.StatusInternalServerError, w.Code)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
 }
 
 func (suite *TestSuite) TestUpdateChicken() {
@@ -289,7 +458,7 @@ func (suite *TestSuite) TestCreateEmployeeInvalidCage() {
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	assert.Equal(suite.T(), http.StatusInternalServerError, w.Code)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
 }
 
 func (suite *TestSuite) TestUpdateEmployee() {
@@ -327,6 +496,12 @@ func (suite *TestSuite) TestDeleteEmployee() {
 	w = httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	// закрепление закрыто, но история осталась для прошлых отчетов
+	var history []model.EmployeeCage
+	suite.db.Where("employee_id = ?", 1).Find(&history)
+	suite.Require().Len(history, 1)
+	suite.Require().NotNil(history[0].ValidTo)
+	assert.True(suite.T(), truncateToDay(time.Now()).Equal(history[0].ValidTo.UTC()))
 }
 
 func (suite *TestSuite) TestGetLowProductivityChickens() {
@@ -357,6 +532,115 @@ func (suite *TestSuite) TestGetMostProductiveChicken() {
 	assert.Contains(suite.T(), stats, "egg_per_month")
 }
 
+func (suite *TestSuite) TestComputedEggRate() {
+	today := truncateToDay(time.Now())
+	suite.Require().NoError(suite.db.Model(&model.Chicken{}).Where("id IN ?", []uint{1, 2}).
+		Update("created_at", today.AddDate(0, 0, -60)).Error)
+
+	// курица 2 неслась 20 дней из последних 30, курица 1 - 5 дней
+	var records []model.Farm
+	for day := 0; day < 20; day++ {
+		records = append(records, model.Farm{Date: today.AddDate(0, 0, -day), CageID: 2, ChickenID: 2, HasEgg: true})
+	}
+	for day := 0; day < 6; day++ {
+		records = append(records, model.Farm{Date: today.AddDate(0, 0, -day), CageID: 1, ChickenID: 1, HasEgg: day > 0})
+	}
+	records = append(records, model.Farm{Date: today.AddDate(0, 0, -40), CageID: 1, ChickenID: 1, HasEgg: true})
+	suite.Require().NoError(suite.db.Create(&records).Error)
+
+	var chicken model.Chicken
+	w := suite.sendJSON("GET", "/api/chickens/1", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &chicken))
+	suite.Require().NotNil(chicken.LayingRate)
+	assert.Equal(suite.T(), 5.0, *chicken.LayingRate)
+
+	// по умолчанию рейтинги строятся по egg_per_month, введенному вручную
+	var stats service.MostProductiveChickenStats
+	w = suite.sendJSON("GET", "/api/reports/most-productive-chicken", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(suite.T(), uint(1), stats.ChickenID)
+
+	w = suite.sendJSON("PUT", "/api/config/egg_rate_source", `{"value": "measured"}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	w = suite.sendJSON("PUT", "/api/config/egg_rate_source", `{"value": "computed"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	w = suite.sendJSON("GET", "/api/reports/most-productive-chicken", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(suite.T(), uint(2), stats.ChickenID)
+	assert.Equal(suite.T(), 20.0, stats.LayingRate)
+
+	var low []model.Chicken
+	w = suite.sendJSON("GET", "/api/chickens/low-productivity", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &low))
+	suite.Require().Len(low, 1)
+	assert.Equal(suite.T(), uint(1), low[0].ID)
+
+	// курица, которая на ферме первый день, оценивается по этому дню
+	w = suite.sendJSON("POST", "/api/chickens", `{"cage_id": 3, "weight": 2.5, "age": 8, "egg_per_month": 10, "breed": "Брама"}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &chicken))
+	suite.Require().NoError(suite.db.Create(&model.Farm{Date: today, CageID: 3, ChickenID: chicken.ID, HasEgg: true}).Error)
+	w = suite.sendJSON("GET", "/api/reports/most-productive-chicken", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(suite.T(), chicken.ID, stats.ChickenID)
+	assert.Equal(suite.T(), 30.0, stats.LayingRate)
+}
+
+func (suite *TestSuite) TestProductivityMatrix() {
+	suite.Require().NoError(suite.db.Create(&[]model.Cage{{Number: 4}, {Number: 5}}).Error)
+	chickens := []model.Chicken{
+		{CageID: 4, Weight: 2.6, HatchDate: model.NewDate(model.HatchDateForAge(13, time.Now())), EggPerMonth: 27, Breed: "Леггорн"},
+		{CageID: 5, Weight: 2.2, HatchDate: model.NewDate(model.HatchDateForAge(5, time.Now())), EggPerMonth: 10, Breed: "Брама"},
+	}
+	suite.Require().NoError(suite.db.Create(&chickens).Error)
+
+	w := suite.sendJSON("GET", "/api/reports/productivity-matrix", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var matrix service.ProductivityMatrix
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &matrix))
+	assert.Equal(suite.T(), []service.Band{{From: 2, To: 2.5}, {From: 2.5, To: 3}, {From: 3, To: 3.5}}, matrix.WeightBands)
+	assert.Len(suite.T(), matrix.AgeBands, 4)
+	suite.Require().Len(matrix.Cells, 3)
+
+	// куры 2.5 кг в 12 месяцев и 2.6 кг в 13 месяцев попадают в одну ячейку
+	cell := matrix.Cells[1][2]
+	assert.Equal(suite.T(), 2, cell.SampleSize)
+	assert.Equal(suite.T(), 26.0, *cell.Avg)
+	assert.Equal(suite.T(), 26.0, *cell.Median)
+	assert.Equal(suite.T(), 25.0, *cell.Min)
+	assert.Equal(suite.T(), 27.0, *cell.Max)
+	assert.Equal(suite.T(), 1, matrix.Cells[0][0].SampleSize)
+	assert.Equal(suite.T(), 0, matrix.Cells[0][1].SampleSize)
+	assert.Nil(suite.T(), matrix.Cells[0][1].Avg)
+
+	// курица 3 кг не попадает в полуинтервал [2, 3)
+	w = suite.sendJSON("GET", "/api/reports/productivity-matrix?weight_edges=2,3&age_edges=0,12,24", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &matrix))
+	suite.Require().Len(matrix.Cells, 1)
+	assert.Equal(suite.T(), 1, matrix.Cells[0][0].SampleSize)
+	assert.Equal(suite.T(), 2, matrix.Cells[0][1].SampleSize)
+
+	w = suite.sendJSON("GET", "/api/reports/productivity-matrix?weight_edges=3,2", "")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "invalid_bands")
+	w = suite.sendJSON("GET", "/api/reports/productivity-matrix?age_step=0.01", "")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "too_many_bands")
+	w = suite.sendJSON("GET", "/api/reports/productivity-matrix?weight_step=1e-300", "")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "too_many_bands")
+	w = suite.sendJSON("GET", "/api/reports/productivity-matrix?weight_edges=2,NaN,3", "")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "invalid_bands")
+	w = suite.sendJSON("GET", "/api/reports/productivity-matrix?age_edges=0,Inf", "")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "invalid_bands")
+	w = suite.sendJSON("GET", "/api/reports/productivity-matrix?weight_edges=2,heavy", "")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
 func (suite *TestSuite) TestGetEmployeeChickenCounts() {
 	req, _ := http.NewRequest("GET", "/api/reports/employee-chicken-counts", nil)
 	w := httptest.NewRecorder()
@@ -396,18 +680,32 @@ func (suite *TestSuite) TestInvalidChickenData() {
 	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
 }
 
+// openBenchmarkDB открывает базу в памяти со схемой из версионных миграций, как в тестах
+func openBenchmarkDB(b *testing.B) *gorm.DB {
+	db, err := database.Open(config.DatabaseConfig{Driver: config.DriverSQLite, Path: ":memory:"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if _, err := migration.NewMigrator(db).Up(); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
 func BenchmarkGetAllChickens(b *testing.B) {
-	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	db.AutoMigrate(&model.Chicken{}, &model.Cage{})
+	db := openBenchmarkDB(b)
 
-	cage := model.Cage{Number: 1}
+	cage := model.Cage{Number: 1, Capacity: 100}
 	db.Create(&cage)
 
 	for i := 0; i < 100; i++ {
 		chicken := model.Chicken{
 			CageID:      1,
+			Slot:        i + 1,
 			Weight:      2.5,
-			Age:         12,
+			HatchDate:   model.NewDate(model.HatchDateForAge(12, time.Now())),
 			EggPerMonth: 25,
 			Breed:       "TestBreed",
 		}
@@ -418,35 +716,227 @@ func BenchmarkGetAllChickens(b *testing.B) {
 	farmRepo := repository.NewFarmRepository(db)
 	chickenService := service.NewChickenService(chickenRepo, farmRepo)
 	chickenController := controller.NewChickenController(chickenService)
+	authService, token := loginAdmin(b, db)
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(controller.Authenticate(authService))
 	chickenController.RegisterRoutes(router)
 
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
 			req, _ := http.NewRequest("GET", "/api/chickens", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				b.Errorf("GET /api/chickens: %d %s", w.Code, w.Body.String())
+				return
+			}
 		}
 	})
 }
 
+// BenchmarkEmployeeReports строит отчеты по сотрудникам на 100 и 10 000 сотрудников.
+// Число запросов к базе на один прогон (queries/op) не должно зависеть от числа сотрудников.
+func BenchmarkEmployeeReports(b *testing.B) {
+	urls := []string{
+		"/api/reports/employee-egg-stats?start_date=2024-01-01&end_date=2024-01-31",
+		"/api/reports/employee-chicken-counts",
+		"/api/reports/attendance?period=2024-01",
+	}
+
+	queriesPerOp := map[int]float64{}
+	for _, employeeCount := range []int{100, 10000} {
+		b.Run(strconv.Itoa(employeeCount), func(b *testing.B) {
+			router, token, db := setupEmployeeReportBenchmark(b, employeeCount)
+
+			queries := 0
+			countQuery := func(*gorm.DB) { queries++ }
+			db.Callback().Query().Register("bench:count_queries", countQuery)
+			db.Callback().Row().Register("bench:count_queries", countQuery)
+			db.Callback().Raw().Register("bench:count_queries", countQuery)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, url := range urls {
+					req, _ := http.NewRequest("GET", url, nil)
+					req.Header.Set("Authorization", "Bearer "+token)
+					w := httptest.NewRecorder()
+					router.ServeHTTP(w, req)
+					if w.Code != http.StatusOK {
+						b.Fatalf("GET %s: %d %s", url, w.Code, w.Body.String())
+					}
+				}
+			}
+			b.StopTimer()
+
+			perOp := float64(queries) / float64(b.N)
+			b.ReportMetric(perOp, "queries/op")
+			queriesPerOp[employeeCount] = perOp
+		})
+	}
+
+	if queriesPerOp[100] != queriesPerOp[10000] {
+		b.Errorf("query count grows with employees: %v", queriesPerOp)
+	}
+}
+
+func setupEmployeeReportBenchmark(b *testing.B, employeeCount int) (*gin.Engine, string, *gorm.DB) {
+	db, err := database.Open(config.DatabaseConfig{Driver: config.DriverSQLite, Path: ":memory:"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if _, err := migration.NewMigrator(db).Up(); err != nil {
+		b.Fatal(err)
+	}
+
+	const cageCount = 100
+	for i := 1; i <= cageCount; i++ {
+		db.Create(&model.Cage{Number: i})
+		db.Create(&model.Chicken{CageID: uint(i), Weight: 2.5, HatchDate: model.NewDate(model.HatchDateForAge(12, time.Now())), EggPerMonth: 25, Breed: "TestBreed"})
+	}
+
+	passports := newTestPassportCipher(b)
+	employees := make([]model.Employee, employeeCount)
+	for i := range employees {
+		number := fmt.Sprintf("%04d %06d", i/1000000, i%1000000)
+		sealed, err := passports.Encrypt(number)
+		if err != nil {
+			b.Fatal(err)
+		}
+		hash := passports.Hash(number)
+		employees[i] = model.Employee{FullName: "Сотрудник " + strconv.Itoa(i), PassportData: sealed, PassportHash: &hash, Salary: 40000}
+	}
+	if err := db.CreateInBatches(&employees, 500).Error; err != nil {
+		b.Fatal(err)
+	}
+
+	assignments := make([]model.EmployeeCage, employeeCount)
+	for i, employee := range employees {
+		assignments[i] = model.EmployeeCage{EmployeeID: employee.ID, CageID: uint(i%cageCount + 1), ValidFrom: seedAssignedFrom}
+	}
+	if err := db.CreateInBatches(&assignments, 500).Error; err != nil {
+		b.Fatal(err)
+	}
+
+	employeeRepo := repository.NewEmployeeRepository(db, passports)
+	reportService := service.NewReportService(repository.NewChickenRepository(db), employeeRepo, repository.NewFarmRepository(db), repository.NewShiftRepository(db))
+	authService, token := loginAdmin(b, db)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(controller.Authenticate(authService))
+	controller.NewReportController(reportService).RegisterRoutes(router)
+
+	return router, token, db
+}
+
+// BenchmarkGetEmptyCages ищет пустые клетки среди 100 000, в каждой десятой из которых живет курица:
+// запросом с NOT EXISTS и прежним способом - сравнением списков идентификаторов в Go
+func BenchmarkGetEmptyCages(b *testing.B) {
+	db, err := database.Open(config.DatabaseConfig{Driver: config.DriverSQLite, Path: ":memory:"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if _, err := migration.NewMigrator(db).Up(); err != nil {
+		b.Fatal(err)
+	}
+
+	const cageCount = 100000
+	cages := make([]model.Cage, cageCount)
+	for i := range cages {
+		cages[i] = model.Cage{Number: i + 1, Capacity: 1}
+	}
+	if err := db.CreateInBatches(&cages, 1000).Error; err != nil {
+		b.Fatal(err)
+	}
+	chickens := make([]model.Chicken, 0, cageCount/10)
+	for i := 0; i < cageCount; i += 10 {
+		chickens = append(chickens, model.Chicken{CageID: cages[i].ID, Weight: 2.5, HatchDate: model.NewDate(model.HatchDateForAge(12, time.Now())), EggPerMonth: 25, Breed: "TestBreed"})
+	}
+	if err := db.CreateInBatches(&chickens, 1000).Error; err != nil {
+		b.Fatal(err)
+	}
+
+	farmRepo := repository.NewFarmRepository(db)
+	run := func(getEmptyCages func() ([]model.Cage, error)) func(b *testing.B) {
+		return func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				empty, err := getEmptyCages()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(empty) != cageCount-len(chickens) {
+					b.Fatalf("expected %d empty cages, got %d", cageCount-len(chickens), len(empty))
+				}
+			}
+		}
+	}
+
+	b.Run("anti_join", run(farmRepo.GetEmptyCages))
+	b.Run("compare_ids", run(func() ([]model.Cage, error) { return emptyCagesByComparingIDs(db) }))
+}
+
+// emptyCagesByComparingIDs - прежняя реализация GetEmptyCages. Клетки загружаются частями:
+// одним IN на все идентификаторы SQLite не выполнит, переменных в запросе слишком много.
+func emptyCagesByComparingIDs(db *gorm.DB) ([]model.Cage, error) {
+	var allCageIDs []uint
+	var occupiedCageIDs []uint
+	if err := db.Model(&model.Cage{}).Pluck("id", &allCageIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&model.Chicken{}).Pluck("cage_id", &occupiedCageIDs).Error; err != nil {
+		return nil, err
+	}
+
+	emptyCageIDs := make([]uint, 0)
+	for _, cageID := range allCageIDs {
+		found := false
+		for _, occupiedID := range occupiedCageIDs {
+			if cageID == occupiedID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			emptyCageIDs = append(emptyCageIDs, cageID)
+		}
+	}
+
+	var emptyCages []model.Cage
+	for start := 0; start < len(emptyCageIDs); start += 10000 {
+		var part []model.Cage
+		end := min(start+10000, len(emptyCageIDs))
+		if err := db.Where("id IN ?", emptyCageIDs[start:end]).Find(&part).Error; err != nil {
+			return nil, err
+		}
+		emptyCages = append(emptyCages, part...)
+	}
+	return emptyCages, nil
+}
+
 func BenchmarkCreateChicken(b *testing.B) {
-	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	db.AutoMigrate(&model.Chicken{}, &model.Cage{})
+	db := openBenchmarkDB(b)
 
-	cage := model.Cage{Number: 1}
+	// клетка вмещает всех кур прогона, иначе запросы упрутся в 409
+	cage := model.Cage{Number: 1, Capacity: b.N}
 	db.Create(&cage)
 
 	chickenRepo := repository.NewChickenRepository(db)
 	farmRepo := repository.NewFarmRepository(db)
 	chickenService := service.NewChickenService(chickenRepo, farmRepo)
 	chickenController := controller.NewChickenController(chickenService)
+	authService, token := loginAdmin(b, db)
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(controller.Authenticate(authService))
 	chickenController.RegisterRoutes(router)
 
 	chicken := model.Chicken{
@@ -462,13 +952,43 @@ func BenchmarkCreateChicken(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		req, _ := http.NewRequest("POST", "/api/chickens", bytes.NewBuffer(jsonData))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			b.Fatalf("POST /api/chickens: %d %s", w.Code, w.Body.String())
+		}
 	}
 }
 
 func TestSuite_Run(t *testing.T) {
-	suite.Run(t, new(TestSuite))
+	suite.Run(t, &TestSuite{
+		dbConfig: config.DatabaseConfig{Driver: config.DriverSQLite, Path: ":memory:"},
+	})
+}
+
+// TestSuite_RunPostgres прогоняет те же сценарии на PostgreSQL. Нужен локальный тестовый
+// сервер, например из docker; его схема public очищается перед каждым тестом.
+func TestSuite_RunPostgres(t *testing.T) {
+	dsn := postgresDSN(t)
+
+	suite.Run(t, &TestSuite{
+		dbConfig: config.DatabaseConfig{Driver: config.DriverPostgres, DSN: dsn},
+	})
+}
+
+// postgresDSN возвращает TEST_POSTGRES_DSN. Без него тест пропускается, а в CI (CI=true)
+// падает: там сервер поднимает workflow, и пропуск означал бы, что PostgreSQL не проверен.
+func postgresDSN(t *testing.T) string {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn != "" {
+		return dsn
+	}
+	if os.Getenv("CI") != "" {
+		t.Fatal("TEST_POSTGRES_DSN is not set")
+	}
+	t.Skip("TEST_POSTGRES_DSN is not set")
+	return ""
 }
 
 func (suite *TestSuite) TearDownTest() {
@@ -494,10 +1014,12 @@ func (suite *TestSuite) TestChickenBusinessLogic() {
 	err := chickenService.CreateChicken(invalidChicken)
 	assert.Error(suite.T(), err)
 	assert.Contains(suite.T(), err.Error(), "cage not found")
+	assert.ErrorIs(suite.T(), err, service.ErrNotFound)
+	assert.ErrorIs(suite.T(), err, service.ErrCageNotFound)
 }
 
 func (suite *TestSuite) TestEmployeeBusinessLogic() {
-	employeeRepo := repository.NewEmployeeRepository(suite.db)
+	employeeRepo := repository.NewEmployeeRepository(suite.db, suite.passports)
 	farmRepo := repository.NewFarmRepository(suite.db)
 	employeeService := service.NewEmployeeService(employeeRepo, farmRepo)
 
@@ -512,3 +1034,1883 @@ func (suite *TestSuite) TestEmployeeBusinessLogic() {
 	assert.Error(suite.T(), err)
 	assert.Contains(suite.T(), err.Error(), "cage not found")
 }
+
+func (suite *TestSuite) TestCreateFarmRecord() {
+	today := time.Now().Format("2006-01-02")
+	body := `{"date": "` + today + `", "cage_id": 1, "chicken_id": 1, "has_egg": true}`
+	req, _ := http.NewRequest("POST", "/api/farm-records", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusCreated, w.Code)
+
+	var record model.Farm
+	err := json.Unmarshal(w.Body.Bytes(), &record)
+	assert.NoError(suite.T(), err)
+	assert.NotZero(suite.T(), record.ID)
+	assert.True(suite.T(), record.HasEgg)
+
+	// последний день периода входит в отчет
+	req, _ = http.NewRequest("GET", "/api/employees/egg-counts?start_date="+today+"&end_date="+today, nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	var counts struct {
+		Counts map[string]int `json:"counts"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &counts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, counts.Counts["1"])
+}
+
+func (suite *TestSuite) TestEmployeeCageHistory() {
+	year, month, day := time.Now().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	chicken := model.Chicken{CageID: 3, Weight: 2.2, HatchDate: model.NewDate(model.HatchDateForAge(10, time.Now())), EggPerMonth: 20, Breed: "Брама"}
+	suite.Require().NoError(suite.db.Create(&chicken).Error)
+	records := []model.Farm{
+		{Date: yesterday, CageID: 1, ChickenID: 1, HasEgg: true},
+		{Date: yesterday, CageID: 3, ChickenID: chicken.ID, HasEgg: true},
+		{Date: today, CageID: 3, ChickenID: chicken.ID, HasEgg: true},
+	}
+	for _, record := range records {
+		suite.Require().NoError(suite.db.Create(&record).Error)
+	}
+
+	// сотрудника переводят с клетки 1 на клетку 3
+	req, _ := http.NewRequest("PUT", "/api/employees/1",
+		bytes.NewBufferString(`{"full_name": "Иванов Иван Иванович", "salary": 50000, "cages": [3]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/employees/1/cage-history", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var history []model.EmployeeCage
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &history))
+	suite.Require().Len(history, 2)
+	assert.Equal(suite.T(), uint(1), history[0].CageID)
+	suite.Require().NotNil(history[0].ValidTo)
+	assert.True(suite.T(), history[0].ValidTo.Equal(today))
+	assert.Equal(suite.T(), uint(3), history[1].CageID)
+	assert.True(suite.T(), history[1].ValidFrom.Equal(today))
+	assert.Nil(suite.T(), history[1].ValidTo)
+
+	employee, err := repository.NewEmployeeRepository(suite.db, suite.passports).GetByID(1)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []uint{3}, employee.Cages)
+
+	// вчерашнее яйцо из клетки 1 остается за сотрудником, вчерашнее из клетки 3 - нет
+	period := "?start_date=" + yesterday.Format("2006-01-02") + "&end_date=" + today.Format("2006-01-02")
+	req, _ = http.NewRequest("GET", "/api/employees/egg-counts"+period, nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var counts struct {
+		Counts map[string]int `json:"counts"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &counts))
+	assert.Equal(suite.T(), 2, counts.Counts["1"])
+
+	req, _ = http.NewRequest("GET", "/api/employees/1/egg-count"+period, nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Contains(suite.T(), w.Body.String(), `"egg_count":2`)
+
+	// повторное сохранение того же списка не открывает новых периодов
+	req, _ = http.NewRequest("PUT", "/api/employees/1",
+		bytes.NewBufferString(`{"full_name": "Иванов Иван Иванович", "salary": 52000, "cages": [3]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	history, err = repository.NewEmployeeRepository(suite.db, suite.passports).GetCageHistory(1)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), history, 2)
+}
+
+func (suite *TestSuite) TestCreateFarmRecordWrongCage() {
+	today := time.Now().Format("2006-01-02")
+	body := `{"date": "` + today + `", "cage_id": 2, "chicken_id": 1, "has_egg": true}`
+	req, _ := http.NewRequest("POST", "/api/farm-records", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "chicken is not in the given cage")
+}
+
+func (suite *TestSuite) TestCreateFarmRecordDuplicate() {
+	today := truncateToDay(time.Now())
+	suite.db.Create(&model.Farm{Date: today, CageID: 1, ChickenID: 1, HasEgg: false})
+
+	body := `{"date": "` + today.Format("2006-01-02") + `", "cage_id": 1, "chicken_id": 1, "has_egg": true}`
+	req, _ := http.NewRequest("POST", "/api/farm-records", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "already exists")
+}
+
+func (suite *TestSuite) TestFilterFarmRecords() {
+	today := truncateToDay(time.Now())
+	suite.db.Create(&model.Farm{Date: today, CageID: 1, ChickenID: 1, HasEgg: true})
+	suite.db.Create(&model.Farm{Date: today, CageID: 2, ChickenID: 2, HasEgg: false})
+	suite.db.Create(&model.Farm{Date: today.AddDate(0, 0, -10), CageID: 1, ChickenID: 1, HasEgg: true})
+
+	url := "/api/farm-records?cage_id=1&start_date=" + today.AddDate(0, 0, -1).Format("2006-01-02") +
+		"&end_date=" + today.Format("2006-01-02")
+	req, _ := http.NewRequest("GET", url, nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var records []model.Farm
+	err := json.Unmarshal(w.Body.Bytes(), &records)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), records, 1)
+	assert.Equal(suite.T(), uint(1), records[0].ChickenID)
+}
+
+func (suite *TestSuite) TestUpdateAndDeleteFarmRecord() {
+	today := truncateToDay(time.Now())
+	record := model.Farm{Date: today, CageID: 1, ChickenID: 1, HasEgg: false}
+	suite.db.Create(&record)
+
+	body := `{"date": "` + today.Format("2006-01-02") + `", "cage_id": 1, "chicken_id": 1, "has_egg": true}`
+	req, _ := http.NewRequest("PUT", "/api/farm-records/"+strconv.Itoa(int(record.ID)), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var updated model.Farm
+	suite.db.First(&updated, record.ID)
+	assert.True(suite.T(), updated.HasEgg)
+
+	req, _ = http.NewRequest("DELETE", "/api/farm-records/"+strconv.Itoa(int(record.ID)), nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/farm-records/"+strconv.Itoa(int(record.ID)), nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+func (suite *TestSuite) TestUpdateFarmRecordAfterChickenMoved() {
+	today := truncateToDay(time.Now())
+	record := model.Farm{Date: today, CageID: 1, ChickenID: 1, HasEgg: false}
+	suite.db.Create(&record)
+	suite.db.Model(&model.Chicken{}).Where("id = ?", 1).Update("cage_id", 3)
+
+	body := `{"date": "` + today.Format("2006-01-02") + `", "cage_id": 1, "chicken_id": 1, "has_egg": true}`
+	req, _ := http.NewRequest("PUT", "/api/farm-records/"+strconv.Itoa(int(record.ID)), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	body = `{"entries": [{"chicken_id": 1, "cage_id": 1, "has_egg": false}]}`
+	req, _ = http.NewRequest("PUT", "/api/collection-sheets/"+today.Format("2006-01-02"), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var updated model.Farm
+	suite.db.First(&updated, record.ID)
+	assert.Equal(suite.T(), uint(1), updated.CageID)
+	assert.False(suite.T(), updated.HasEgg)
+
+	body = `{"date": "` + today.Format("2006-01-02") + `", "cage_id": 2, "chicken_id": 1, "has_egg": true}`
+	req, _ = http.NewRequest("PUT", "/api/farm-records/"+strconv.Itoa(int(record.ID)), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+}
+
+func (suite *TestSuite) TestFarmRecordsFollowChickenCageHistory() {
+	today := truncateToDay(time.Now())
+	yesterday := today.AddDate(0, 0, -1).Format("2006-01-02")
+
+	w := suite.sendJSON("PUT", "/api/chickens/1", `{"cage_id": 3, "weight": 2.5, "age": 12, "egg_per_month": 25, "breed": "Леггорн"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// вчера курица еще сидела в клетке 1, задним числом ее отметку можно внести только туда
+	w = suite.sendJSON("POST", "/api/farm-records", `{"date": "`+yesterday+`", "cage_id": 3, "chicken_id": 1, "has_egg": true}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "chicken is not in the given cage on that date")
+
+	w = suite.sendJSON("POST", "/api/farm-records", `{"date": "`+yesterday+`", "cage_id": 1, "chicken_id": 1, "has_egg": true}`)
+	assert.Equal(suite.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	w = suite.sendJSON("POST", "/api/farm-records", `{"date": "`+today.Format("2006-01-02")+`", "cage_id": 3, "chicken_id": 1, "has_egg": true}`)
+	assert.Equal(suite.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	// до заселения отметок нет
+	w = suite.sendJSON("POST", "/api/farm-records", `{"date": "2019-12-31", "cage_id": 2, "chicken_id": 2, "has_egg": true}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "chicken was not in the given cage on that date")
+
+	// лист сбора за вчера показывает курицу в той клетке, где она тогда сидела
+	req, _ := http.NewRequest("GET", "/api/collection-sheets/"+yesterday, nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	var sheet service.CollectionSheet
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &sheet))
+	suite.Require().Len(sheet.Entries, 2)
+	assert.Equal(suite.T(), uint(1), sheet.Entries[0].ChickenID)
+	assert.Equal(suite.T(), uint(1), sheet.Entries[0].CageID)
+}
+
+func truncateToDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func (suite *TestSuite) TestGetCollectionSheet() {
+	today := truncateToDay(time.Now())
+	suite.db.Create(&model.Farm{Date: today, CageID: 2, ChickenID: 2, HasEgg: true})
+
+	req, _ := http.NewRequest("GET", "/api/collection-sheets/"+today.Format("2006-01-02"), nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var sheet service.CollectionSheet
+	err := json.Unmarshal(w.Body.Bytes(), &sheet)
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(sheet.Entries, 2)
+	assert.Equal(suite.T(), 1, sheet.Entries[0].CageNumber)
+	assert.Nil(suite.T(), sheet.Entries[0].Record)
+	suite.Require().NotNil(sheet.Entries[1].Record)
+	assert.True(suite.T(), sheet.Entries[1].Record.HasEgg)
+}
+
+func (suite *TestSuite) TestSaveCollectionSheet() {
+	today := truncateToDay(time.Now())
+	suite.db.Create(&model.Farm{Date: today, CageID: 2, ChickenID: 2, HasEgg: true})
+
+	body := `{"entries": [{"chicken_id": 1, "has_egg": true}, {"chicken_id": 2, "cage_id": 2, "has_egg": false}]}`
+	req, _ := http.NewRequest("PUT", "/api/collection-sheets/"+today.Format("2006-01-02"), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var records []model.Farm
+	suite.db.Order("chicken_id").Find(&records)
+	suite.Require().Len(records, 2)
+	assert.True(suite.T(), records[0].HasEgg)
+	assert.False(suite.T(), records[1].HasEgg)
+}
+
+func (suite *TestSuite) TestSaveCollectionSheetIsAtomic() {
+	today := truncateToDay(time.Now())
+
+	body := `{"entries": [{"chicken_id": 1, "has_egg": true}, {"chicken_id": 2, "cage_id": 1, "has_egg": true}]}`
+	req, _ := http.NewRequest("PUT", "/api/collection-sheets/"+today.Format("2006-01-02"), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var count int64
+	suite.db.Model(&model.Farm{}).Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
+}
+
+func (suite *TestSuite) TestGetAllCagesWithOccupancy() {
+	req, _ := http.NewRequest("GET", "/api/cages", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var cages []service.CageOccupancy
+	err := json.Unmarshal(w.Body.Bytes(), &cages)
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(cages, 3)
+	assert.Equal(suite.T(), 1, cages[0].Occupancy)
+	assert.Equal(suite.T(), 1, cages[0].Capacity)
+	assert.Equal(suite.T(), 0, cages[2].Occupancy)
+}
+
+func (suite *TestSuite) TestGetEmptyCages() {
+	req, _ := http.NewRequest("GET", "/api/cages/empty", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var cages []model.Cage
+	err := json.Unmarshal(w.Body.Bytes(), &cages)
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(cages, 1)
+	assert.Equal(suite.T(), 3, cages[0].Number)
+}
+
+func (suite *TestSuite) TestGetCageDetails() {
+	req, _ := http.NewRequest("GET", "/api/cages/1", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var cage service.CageDetails
+	err := json.Unmarshal(w.Body.Bytes(), &cage)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, cage.Occupancy)
+	suite.Require().Len(cage.Chickens, 1)
+	assert.Equal(suite.T(), "Леггорн", cage.Chickens[0].Breed)
+	suite.Require().Len(cage.Employees, 1)
+	assert.Equal(suite.T(), uint(1), cage.Employees[0].ID)
+}
+
+func (suite *TestSuite) TestCreateCageWithCapacity() {
+	req, _ := http.NewRequest("POST", "/api/cages", bytes.NewBufferString(`{"number": 10, "capacity": 2}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusCreated, w.Code)
+
+	var cage model.Cage
+	err := json.Unmarshal(w.Body.Bytes(), &cage)
+	assert.NoError(suite.T(), err)
+
+	for i := 0; i < 3; i++ {
+		chicken := model.Chicken{CageID: cage.ID, Weight: 2.5, Age: 12, EggPerMonth: 20, Breed: "Леггорн"}
+		jsonData, _ := json.Marshal(chicken)
+		req, _ = http.NewRequest("POST", "/api/chickens", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		if i < 2 {
+			assert.Equal(suite.T(), http.StatusCreated, w.Code)
+		} else {
+			assert.Equal(suite.T(), http.StatusConflict, w.Code)
+		}
+	}
+
+	w = suite.sendJSON("PUT", fmt.Sprintf("/api/cages/%d", cage.ID), `{"number": 10, "capacity": 1}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "capacity_below_occupancy")
+
+	w = suite.sendJSON("PUT", fmt.Sprintf("/api/cages/%d", cage.ID), `{"number": 10, "capacity": 3}`)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+func (suite *TestSuite) TestCreateCageDuplicateNumber() {
+	req, _ := http.NewRequest("POST", "/api/cages", bytes.NewBufferString(`{"number": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "already exists")
+}
+
+func (suite *TestSuite) TestDeleteCageInUse() {
+	req, _ := http.NewRequest("DELETE", "/api/cages/1", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "occupied")
+
+	suite.db.Create(&model.EmployeeCage{EmployeeID: 1, CageID: 3})
+	req, _ = http.NewRequest("DELETE", "/api/cages/3", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "assigned to employees")
+
+	suite.db.Where("cage_id = ?", 3).Delete(&model.EmployeeCage{})
+	req, _ = http.NewRequest("DELETE", "/api/cages/3", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+func (suite *TestSuite) TestEggStatsUseConfiguredPrice() {
+	today := truncateToDay(time.Now())
+	suite.db.Create(&model.Farm{Date: today, CageID: 1, ChickenID: 1, HasEgg: true})
+	suite.db.Create(&model.Farm{Date: today, CageID: 2, ChickenID: 2, HasEgg: true})
+
+	req, _ := http.NewRequest("PUT", "/api/config/egg_price", bytes.NewBufferString(`{"value": "12.5"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	url := "/api/reports/egg-stats?start_date=" + today.Format("2006-01-02") +
+		"&end_date=" + today.Format("2006-01-02")
+	req, _ = http.NewRequest("GET", url, nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var stats map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &stats)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2.0, stats["total_eggs"])
+	assert.Equal(suite.T(), 12.5, stats["egg_price"])
+	assert.Equal(suite.T(), 25.0, stats["total_cost"])
+}
+
+func (suite *TestSuite) TestGetConfigDefaults() {
+	req, _ := http.NewRequest("GET", "/api/config/egg_price", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var param model.ConfigParam
+	err := json.Unmarshal(w.Body.Bytes(), &param)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "10", param.Value)
+}
+
+func (suite *TestSuite) TestSetConfigValidation() {
+	req, _ := http.NewRequest("PUT", "/api/config/egg_price", bytes.NewBufferString(`{"value": "-3"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+
+	req, _ = http.NewRequest("PUT", "/api/config/unknown_key", bytes.NewBufferString(`{"value": "1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+func (suite *TestSuite) TestEggStatsUsePriceHistory() {
+	lastMonth := truncateToDay(time.Now()).AddDate(0, -1, 0)
+	yesterday := truncateToDay(time.Now()).AddDate(0, 0, -1)
+	suite.db.Create(&model.Farm{Date: lastMonth, CageID: 1, ChickenID: 1, HasEgg: true})
+	suite.db.Create(&model.Farm{Date: lastMonth, CageID: 2, ChickenID: 2, HasEgg: true})
+	suite.db.Create(&model.Farm{Date: yesterday, CageID: 1, ChickenID: 1, HasEgg: true})
+
+	body := `{"price": 15, "effective_from": "` + yesterday.Format("2006-01-02") + `"}`
+	req, _ := http.NewRequest("POST", "/api/egg-prices", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusCreated, w.Code)
+
+	url := "/api/reports/egg-stats?start_date=" + lastMonth.AddDate(0, 0, -1).Format("2006-01-02") +
+		"&end_date=" + time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	req, _ = http.NewRequest("GET", url, nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var stats service.EggStats
+	err := json.Unmarshal(w.Body.Bytes(), &stats)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, stats.TotalEggs)
+	assert.Equal(suite.T(), 2*10.0+15.0, stats.TotalCost)
+	assert.Equal(suite.T(), 15.0, stats.EggPrice)
+	suite.Require().Len(stats.PricePeriods, 2)
+	assert.Equal(suite.T(), 10.0, stats.PricePeriods[0].Price)
+	assert.Equal(suite.T(), 2, stats.PricePeriods[0].EggCount)
+	assert.Equal(suite.T(), 15.0, stats.PricePeriods[1].Price)
+	assert.Nil(suite.T(), stats.PricePeriods[1].EffectiveTo)
+}
+
+func (suite *TestSuite) TestSetConfigEggPriceKeepsHistory() {
+	lastMonth := truncateToDay(time.Now()).AddDate(0, -1, 0)
+	suite.db.Create(&model.Farm{Date: lastMonth, CageID: 1, ChickenID: 1, HasEgg: true})
+
+	req, _ := http.NewRequest("PUT", "/api/config/egg_price", bytes.NewBufferString(`{"value": "20"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var prices []model.EggPrice
+	suite.db.Order("effective_from").Find(&prices)
+	suite.Require().Len(prices, 2)
+	assert.Equal(suite.T(), 10.0, prices[0].Price)
+	assert.Equal(suite.T(), 20.0, prices[1].Price)
+
+	url := "/api/reports/egg-stats?start_date=" + lastMonth.AddDate(0, 0, -1).Format("2006-01-02") +
+		"&end_date=" + lastMonth.AddDate(0, 0, 1).Format("2006-01-02")
+	req, _ = http.NewRequest("GET", url, nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	var stats service.EggStats
+	err := json.Unmarshal(w.Body.Bytes(), &stats)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 10.0, stats.TotalCost)
+	assert.Equal(suite.T(), 20.0, stats.EggPrice)
+}
+
+func (suite *TestSuite) TestErrorResponseHasCode() {
+	req, _ := http.NewRequest("GET", "/api/chickens/999", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	var body map[string]string
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "chicken_not_found", body["code"])
+	assert.Equal(suite.T(), "chicken not found", body["error"])
+
+	jsonData, _ := json.Marshal(model.Chicken{CageID: 1, Weight: 2.8, Age: 15, EggPerMonth: 28, Breed: "Нью-Гемпшир"})
+	req, _ = http.NewRequest("POST", "/api/chickens", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "cage_occupied", body["code"])
+}
+
+func (suite *TestSuite) TestChickenAgeFromHatchDate() {
+	hatchDate := model.HatchDateForAge(7, time.Now())
+	w := suite.sendJSON("POST", "/api/chickens", `{"cage_id": 3, "weight": 2.5, "hatch_date": "`+
+		hatchDate.Format("2006-01-02")+`", "egg_per_month": 10, "breed": "Брама"}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(suite.T(), w.Body.String(), `"hatch_date":"`+hatchDate.Format("2006-01-02")+`"`)
+	var chicken model.Chicken
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &chicken))
+	assert.Equal(suite.T(), 7, chicken.Age)
+	assert.True(suite.T(), chicken.HatchDate.Equal(hatchDate))
+
+	// дата вылупления, как и остальные даты API, принимается только в формате YYYY-MM-DD
+	w = suite.sendJSON("POST", "/api/chickens", `{"cage_id": 3, "weight": 2.5, "hatch_date": "`+
+		hatchDate.Format(time.RFC3339)+`", "egg_per_month": 10, "breed": "Брама"}`)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "expected format YYYY-MM-DD")
+
+	// возраст растет вместе с календарем, а не хранится
+	suite.Require().NoError(suite.db.Model(&model.Chicken{}).Where("id = ?", chicken.ID).
+		Update("hatch_date", model.HatchDateForAge(19, time.Now())).Error)
+	w = suite.sendJSON("GET", "/api/chickens/"+strconv.Itoa(int(chicken.ID)), "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &chicken))
+	assert.Equal(suite.T(), 19, chicken.Age)
+
+	w = suite.sendJSON("GET", "/api/chickens/avg-eggs?weight=2.5&age=19", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"avg_eggs":10`)
+	w = suite.sendJSON("GET", "/api/chickens/avg-eggs?weight=2.5&age=12", "")
+	assert.Contains(suite.T(), w.Body.String(), `"avg_eggs":25`)
+
+	// клиент, присылающий прежний возраст, не сдвигает дату вылупления
+	w = suite.sendJSON("PUT", "/api/chickens/"+strconv.Itoa(int(chicken.ID)),
+		`{"cage_id": 3, "weight": 2.6, "age": 19, "egg_per_month": 10, "breed": "Брама"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(suite.T(), w.Body.String(), `"hatch_date":"`+model.HatchDateForAge(19, time.Now()).Format("2006-01-02")+`"`)
+	assert.Contains(suite.T(), w.Body.String(), `"weight_loss_alerts"`)
+	var stored model.Chicken
+	suite.Require().NoError(suite.db.First(&stored, chicken.ID).Error)
+	assert.True(suite.T(), stored.HatchDate.Equal(model.HatchDateForAge(19, time.Now())))
+
+	w = suite.sendJSON("POST", "/api/chickens", `{"cage_id": 3, "weight": 2.5, "egg_per_month": 10, "breed": "Брама"}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "hatch_date_required")
+
+	w = suite.sendJSON("POST", "/api/chickens", `{"cage_id": 3, "weight": 2.5, "hatch_date": "`+
+		time.Now().AddDate(0, 0, 2).Format("2006-01-02")+`", "egg_per_month": 10, "breed": "Брама"}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "invalid_hatch_date")
+}
+
+func (suite *TestSuite) TestChickenWeighIns() {
+	day := func(daysAgo int) string {
+		return time.Now().AddDate(0, 0, -daysAgo).Format("2006-01-02")
+	}
+
+	w := suite.sendJSON("POST", "/api/chickens/1/weights", `{"date": "`+day(20)+`", "weight": 2.5}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var result service.WeighInResult
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Empty(suite.T(), result.Alerts)
+
+	// курица 1 похудела на 20% при допустимых по умолчанию 10%
+	w = suite.sendJSON("POST", "/api/chickens/weights", `{"weigh_ins": [
+		{"chicken_id": 1, "date": "`+day(10)+`", "weight": 2.0},
+		{"chicken_id": 2, "date": "`+day(10)+`", "weight": 3.1}]}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Len(suite.T(), result.WeighIns, 2)
+	suite.Require().Len(result.Alerts, 1)
+	assert.Equal(suite.T(), uint(1), result.Alerts[0].ChickenID)
+	assert.Equal(suite.T(), 20.0, result.Alerts[0].LossPercent)
+
+	// текущий вес берется из последнего взвешивания, а не из последнего записанного
+	w = suite.sendJSON("POST", "/api/chickens/1/weights", `{"date": "`+day(15)+`", "weight": 2.6}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	// взвешивание задним числом сравнивается и со следующим: с 2.6 до 2.0 - минус 23.1%
+	result = service.WeighInResult{}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	suite.Require().Len(result.Alerts, 1)
+	assert.Equal(suite.T(), 2.6, result.Alerts[0].PreviousWeight)
+	assert.Equal(suite.T(), 23.1, result.Alerts[0].LossPercent)
+	var chicken model.Chicken
+	w = suite.sendJSON("GET", "/api/chickens/1", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &chicken))
+	assert.Equal(suite.T(), 2.0, chicken.Weight)
+
+	w = suite.sendJSON("POST", "/api/chickens/1/weights", `{"date": "`+day(15)+`", "weight": 2.7}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "duplicate_weigh_in")
+
+	// пакет записывается целиком или не записывается вовсе
+	w = suite.sendJSON("POST", "/api/chickens/weights", `{"weigh_ins": [
+		{"chicken_id": 2, "date": "`+day(5)+`", "weight": 3.0},
+		{"chicken_id": 999, "weight": 3.0}]}`)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	w = suite.sendJSON("GET", "/api/chickens/2/weights", "")
+	var history []model.WeighIn
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Len(suite.T(), history, 1)
+
+	// правка веса через PUT попадает в историю
+	w = suite.sendJSON("PUT", "/api/chickens/1", `{"cage_id": 1, "weight": 1.9, "age": 12, "egg_per_month": 25, "breed": "Леггорн"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var updated struct {
+		WeightLossAlerts []service.WeightLossAlert `json:"weight_loss_alerts"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Empty(suite.T(), updated.WeightLossAlerts)
+	w = suite.sendJSON("GET", "/api/chickens/1/weights?start_date="+day(15), "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &history))
+	suite.Require().Len(history, 3)
+	assert.Equal(suite.T(), []float64{2.6, 2.0, 1.9}, []float64{history[0].Weight, history[1].Weight, history[2].Weight})
+
+	w = suite.sendJSON("GET", "/api/reports/weight-loss-alerts?start_date="+day(30)+"&end_date="+day(0), "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var report struct {
+		Alerts []service.WeightLossAlert `json:"alerts"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	suite.Require().Len(report.Alerts, 1)
+	assert.Equal(suite.T(), 2.6, report.Alerts[0].PreviousWeight)
+	assert.Equal(suite.T(), 23.1, report.Alerts[0].LossPercent)
+
+	w = suite.sendJSON("PUT", "/api/config/weight_loss_alert_percent", `{"value": "3"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	w = suite.sendJSON("GET", "/api/reports/weight-loss-alerts?start_date="+day(0)+"&end_date="+day(0), "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	suite.Require().Len(report.Alerts, 1)
+	assert.Equal(suite.T(), 2.0, report.Alerts[0].PreviousWeight)
+
+	// с порогом 3% правка веса через PUT тоже предупреждает о потере: с 1.9 до 1.8
+	w = suite.sendJSON("PUT", "/api/chickens/1", `{"cage_id": 1, "weight": 1.8, "age": 12, "egg_per_month": 25, "breed": "Леггорн"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &updated))
+	suite.Require().Len(updated.WeightLossAlerts, 1)
+	assert.Equal(suite.T(), 2.0, updated.WeightLossAlerts[0].PreviousWeight)
+
+	// взвешивание с нулевым весом из старых данных не дает бесконечного процента
+	suite.Require().NoError(suite.db.Create(&model.WeighIn{ChickenID: 2, Date: truncateToDay(time.Now()).AddDate(0, 0, -3), Weight: 0}).Error)
+	w = suite.sendJSON("POST", "/api/chickens/2/weights", `{"weight": 3.0}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Empty(suite.T(), result.Alerts)
+
+	w = suite.sendJSON("PUT", "/api/config/weight_loss_alert_percent", `{"value": "150"}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestAgeInMonths(t *testing.T) {
+	now := time.Date(2024, 3, 31, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, 0, model.AgeInMonths(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), now))
+	assert.Equal(t, 1, model.AgeInMonths(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), now))
+	assert.Equal(t, 12, model.AgeInMonths(time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC), now))
+	assert.Equal(t, 11, model.AgeInMonths(time.Date(2023, 4, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 14, 0, 0, 0, 0, time.UTC)))
+
+	// в феврале нет 31 числа, поэтому месяц исполняется 29 февраля
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), model.HatchDateForAge(1, now))
+	for age := 0; age <= 240; age++ {
+		assert.Equal(t, age, model.AgeInMonths(model.HatchDateForAge(age, now), now))
+	}
+}
+
+func (suite *TestSuite) TestCreateChickenValidation() {
+	invalidData := `{"cage_id": 3, "weight": -1, "age": 500, "egg_per_month": 500, "breed": ""}`
+	req, _ := http.NewRequest("POST", "/api/chickens", bytes.NewBufferString(invalidData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var body struct {
+		Code   string                  `json:"code"`
+		Fields []controller.FieldError `json:"fields"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "validation_failed", body.Code)
+
+	fields := make(map[string]string)
+	for _, field := range body.Fields {
+		fields[field.Field] = field.Rule
+	}
+	assert.Equal(suite.T(), map[string]string{
+		"weight":        "gt",
+		"age":           "lte",
+		"egg_per_month": "lte",
+		"breed":         "required",
+	}, fields)
+}
+
+func (suite *TestSuite) TestUpdateChickenValidation() {
+	invalidData := `{"cage_id": 1, "weight": 2.5, "age": 12, "egg_per_month": 20, "breed": ""}`
+	req, _ := http.NewRequest("PUT", "/api/chickens/1", bytes.NewBufferString(invalidData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"field":"breed"`)
+}
+
+func (suite *TestSuite) TestEmployeeValidation() {
+	invalidData := `{"full_name": "", "passport_data": "12345", "salary": -100, "cages": [0]}`
+	req, _ := http.NewRequest("POST", "/api/employees", bytes.NewBufferString(invalidData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var body struct {
+		Fields []controller.FieldError `json:"fields"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	assert.NoError(suite.T(), err)
+
+	fields := make(map[string]string)
+	for _, field := range body.Fields {
+		fields[field.Field] = field.Rule
+	}
+	assert.Equal(suite.T(), map[string]string{
+		"full_name":     "required",
+		"passport_data": "passport",
+		"salary":        "gte",
+		"cages[0]":      "gt",
+	}, fields)
+
+	req, _ = http.NewRequest("PUT", "/api/employees/1", bytes.NewBufferString(invalidData))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+}
+
+// login входит под указанным пользователем и возвращает токен
+func (suite *TestSuite) login(username, password string) string {
+	body := `{"username": "` + username + `", "password": "` + password + `"}`
+	req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var result struct {
+		Token string `json:"token"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	return result.Token
+}
+
+func (suite *TestSuite) createUser(body string) {
+	req, _ := http.NewRequest("POST", "/api/users", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+}
+
+// sendJSON выполняет запрос с JSON-телом от имени администратора
+func (suite *TestSuite) sendJSON(method, url, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *TestSuite) TestExclusiveCagePolicy() {
+	w := suite.sendJSON("PUT", "/api/config/cage_assignment_policy", `{"value": "solo"}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+
+	// при совместном закреплении клетку 1 можно дать второму сотруднику
+	w = suite.sendJSON("PUT", "/api/employees/2", `{"full_name": "Петров Петр Петрович", "salary": 45000, "cages": [2, 1]}`)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = suite.sendJSON("PUT", "/api/config/cage_assignment_policy", `{"value": "exclusive"}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "cages_shared")
+
+	w = suite.sendJSON("PUT", "/api/employees/2", `{"full_name": "Петров Петр Петрович", "salary": 45000, "cages": [2]}`)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = suite.sendJSON("PUT", "/api/config/cage_assignment_policy", `{"value": "exclusive"}`)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = suite.sendJSON("POST", "/api/employees",
+		`{"full_name": "Сидоров Сидор", "passport_data": "3456 789012", "salary": 40000, "cages": [1]}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "cage_taken")
+
+	w = suite.sendJSON("PUT", "/api/employees/2", `{"full_name": "Петров Петр Петрович", "salary": 45000, "cages": [2, 1]}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	// сотрудник сохраняет свои клетки и может взять свободную
+	w = suite.sendJSON("PUT", "/api/employees/1", `{"full_name": "Иванов Иван Иванович", "salary": 50000, "cages": [1, 3]}`)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+func (suite *TestSuite) TestReassignCage() {
+	w := suite.sendJSON("GET", "/api/reports/unattended-cages", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var unattended []model.Cage
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &unattended))
+	suite.Require().Len(unattended, 1)
+	assert.Equal(suite.T(), 3, unattended[0].Number)
+
+	w = suite.sendJSON("POST", "/api/cages/1/reassign", `{"from_employee_id": 2, "to_employee_id": 2}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "same_employee")
+
+	w = suite.sendJSON("POST", "/api/cages/1/reassign", `{"to_employee_id": 99}`)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	w = suite.sendJSON("POST", "/api/cages/1/reassign", `{"from_employee_id": 2, "to_employee_id": 3}`)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	w = suite.sendJSON("POST", "/api/cages/1/reassign", `{"to_employee_id": 2}`)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var details service.CageDetails
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &details))
+	suite.Require().Len(details.Employees, 1)
+	assert.Equal(suite.T(), uint(2), details.Employees[0].ID)
+
+	employeeRepo := repository.NewEmployeeRepository(suite.db, suite.passports)
+	employee, err := employeeRepo.GetByID(1)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), employee.Cages)
+
+	// клетка 3 ничья, снимать ее не с кого
+	w = suite.sendJSON("POST", "/api/cages/3/reassign", `{"from_employee_id": 2, "to_employee_id": 1}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "cage_not_assigned")
+
+	w = suite.sendJSON("POST", "/api/cages/3/reassign", `{"to_employee_id": 1}`)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = suite.sendJSON("GET", "/api/reports/unattended-cages", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &unattended))
+	assert.Empty(suite.T(), unattended)
+}
+
+func (suite *TestSuite) TestBalanceWorkload() {
+	// ряд A из клеток 1 и 4 должен достаться одному сотруднику
+	suite.Require().NoError(suite.db.Model(&model.Cage{}).Where("id = ?", 1).Update("row", "A").Error)
+	suite.Require().NoError(suite.db.Create(&model.Cage{Number: 4, Capacity: 3, Row: "A"}).Error)
+	suite.Require().NoError(suite.db.Create(&model.Cage{Number: 5, Capacity: 3}).Error)
+	chickens := []model.Chicken{
+		{CageID: 4, Slot: 1, Weight: 2.1, HatchDate: model.NewDate(model.HatchDateForAge(9, time.Now())), EggPerMonth: 20, Breed: "Брама"},
+		{CageID: 5, Slot: 1, Weight: 2.3, HatchDate: model.NewDate(model.HatchDateForAge(11, time.Now())), EggPerMonth: 21, Breed: "Брама"},
+		{CageID: 5, Slot: 2, Weight: 2.4, HatchDate: model.NewDate(model.HatchDateForAge(11, time.Now())), EggPerMonth: 22, Breed: "Брама"},
+		{CageID: 5, Slot: 3, Weight: 2.2, HatchDate: model.NewDate(model.HatchDateForAge(10, time.Now())), EggPerMonth: 23, Breed: "Брама"},
+	}
+	for _, chicken := range chickens {
+		suite.Require().NoError(suite.db.Create(&chicken).Error)
+	}
+
+	w := suite.sendJSON("POST", "/api/workload/balance?dry_run=true", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var plan service.BalancePlan
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &plan))
+	assert.False(suite.T(), plan.Applied)
+	assert.Equal(suite.T(), 0, plan.SpreadBefore)
+	assert.Equal(suite.T(), 0, plan.SpreadAfter)
+
+	targets := make(map[int]uint)
+	for _, change := range plan.Changes {
+		targets[change.CageNumber] = change.ToEmployeeID
+	}
+	// клетка 5 (3 курицы) уходит первому сотруднику, ряд A (2 курицы) - второму,
+	// клетка 2 остается у второго, пустая клетка 3 - первому
+	assert.Equal(suite.T(), map[int]uint{1: 2, 3: 1, 4: 2, 5: 1}, targets)
+
+	employeeRepo := repository.NewEmployeeRepository(suite.db, suite.passports)
+	employee, err := employeeRepo.GetByID(1)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []uint{1}, employee.Cages, "dry run must not change assignments")
+
+	// предельная нагрузка не дает отдать клетку 5 первому сотруднику
+	w = suite.sendJSON("PUT", "/api/employees/1",
+		`{"full_name": "Иванов Иван Иванович", "salary": 50000, "max_chickens": 2, "cages": [1]}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	w = suite.sendJSON("POST", "/api/workload/balance", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &plan))
+	assert.True(suite.T(), plan.Applied)
+	assert.Equal(suite.T(), 2, plan.SpreadAfter)
+
+	employee, err = employeeRepo.GetByID(1)
+	suite.Require().NoError(err)
+	assert.ElementsMatch(suite.T(), []uint{1, 3, 4}, employee.Cages)
+	employee, err = employeeRepo.GetByID(2)
+	suite.Require().NoError(err)
+	assert.ElementsMatch(suite.T(), []uint{2, 5}, employee.Cages)
+
+	// повторная балансировка ничего не меняет
+	w = suite.sendJSON("POST", "/api/workload/balance?dry_run=true", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &plan))
+	assert.Empty(suite.T(), plan.Changes)
+
+	w = suite.sendJSON("PUT", "/api/employees/2",
+		`{"full_name": "Петров Петр Петрович", "salary": 45000, "max_chickens": 2, "cages": [2, 5]}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	w = suite.sendJSON("POST", "/api/workload/balance?dry_run=true", "")
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "max_load_exceeded")
+}
+
+func (suite *TestSuite) TestPayroll() {
+	for day := 1; day <= 5; day++ {
+		record := model.Farm{Date: time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC), CageID: 1, ChickenID: 1, HasEgg: true}
+		suite.Require().NoError(suite.db.Create(&record).Error)
+	}
+
+	w := suite.sendJSON("PUT", "/api/config/payroll_egg_target", `{"value": "3"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	w = suite.sendJSON("PUT", "/api/config/payroll_egg_bonus", `{"value": "10"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	w = suite.sendJSON("POST", "/api/payroll/absences", `{"employee_id": 1, "date": "2024-03-04", "reason": "sick"}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	w = suite.sendJSON("POST", "/api/payroll/absences", `{"employee_id": 1, "date": "2024-03-04"}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	w = suite.sendJSON("GET", "/api/payroll/2024-03", "")
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	w = suite.sendJSON("POST", "/api/payroll/2024-13", "")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "invalid_period")
+
+	w = suite.sendJSON("POST", "/api/payroll/2024-03", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var payroll service.Payroll
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &payroll))
+	assert.Equal(suite.T(), model.PayslipDraft, payroll.Status)
+	suite.Require().Len(payroll.Payslips, 2)
+
+	// 5 яиц при плане 3 - премия 2 * 10; один пропуск из 21 рабочего дня марта
+	first := payroll.Payslips[0]
+	assert.Equal(suite.T(), 5, first.EggCount)
+	assert.Equal(suite.T(), 20.0, first.Bonus)
+	assert.Equal(suite.T(), 2380.95, first.Deductions)
+	assert.Equal(suite.T(), 47639.05, first.Total)
+	suite.Require().Len(first.Lines, 3)
+	assert.Equal(suite.T(), model.PayslipLineAbsence, first.Lines[2].Kind)
+	assert.Equal(suite.T(), -2380.95, first.Lines[2].Amount)
+
+	second := payroll.Payslips[1]
+	assert.Equal(suite.T(), 45000.0, second.Total)
+	assert.Len(suite.T(), second.Lines, 1)
+	assert.Equal(suite.T(), 92639.05, payroll.Total)
+
+	// черновик пересчитывается с новыми данными
+	w = suite.sendJSON("POST", "/api/payroll/absences", `{"employee_id": 2, "date": "2024-03-05"}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	w = suite.sendJSON("POST", "/api/payroll/2024-03", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &payroll))
+	assert.Equal(suite.T(), 1, payroll.Payslips[1].AbsenceDays)
+
+	w = suite.sendJSON("POST", "/api/payroll/2024-03/finalize", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &payroll))
+	assert.Equal(suite.T(), model.PayslipFinalized, payroll.Status)
+	assert.NotNil(suite.T(), payroll.Payslips[0].FinalizedAt)
+
+	// утвержденные листки не меняются
+	suite.Require().NoError(suite.db.Model(&model.Employee{}).Where("id = ?", 2).Update("salary", 99999).Error)
+	w = suite.sendJSON("POST", "/api/payroll/2024-03", "")
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "payroll_finalized")
+
+	w = suite.sendJSON("POST", "/api/payroll/absences", `{"employee_id": 2, "date": "2024-03-06"}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	w = suite.sendJSON("GET", "/api/payroll/absences?period=2024-03", "")
+	var absences []model.Absence
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &absences))
+	suite.Require().Len(absences, 2)
+	w = suite.sendJSON("DELETE", "/api/payroll/absences/"+strconv.Itoa(int(absences[0].ID)), "")
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	w = suite.sendJSON("POST", "/api/payroll/2024-03/finalize", "")
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	w = suite.sendJSON("GET", "/api/payroll/2024-03", "")
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &payroll))
+	assert.Equal(suite.T(), 45000.0, payroll.Payslips[1].BaseSalary)
+}
+
+func (suite *TestSuite) TestShiftsAndClocking() {
+	startsAt := time.Now().UTC().Add(2 * time.Hour).Truncate(time.Minute)
+	shiftBody := func(employeeID int, from, to time.Time) string {
+		return `{"employee_id": ` + strconv.Itoa(employeeID) + `, "starts_at": "` + from.Format(time.RFC3339) +
+			`", "ends_at": "` + to.Format(time.RFC3339) + `"}`
+	}
+
+	w := suite.sendJSON("POST", "/api/shifts", shiftBody(1, startsAt, startsAt.Add(8*time.Hour)))
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var shift model.Shift
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &shift))
+
+	w = suite.sendJSON("POST", "/api/shifts", shiftBody(1, startsAt.Add(4*time.Hour), startsAt.Add(12*time.Hour)))
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "shift_overlap")
+
+	w = suite.sendJSON("POST", "/api/shifts", shiftBody(2, startsAt, startsAt.Add(-time.Hour)))
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "invalid_shift_time")
+
+	day := startsAt.Format("2006-01-02")
+	w = suite.sendJSON("GET", "/api/shifts?start_date="+day+"&end_date="+day+"&employee_id=1", "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	var shifts []model.Shift
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &shifts))
+	assert.Len(suite.T(), shifts, 1)
+
+	// администратор отмечает сотрудника явно
+	w = suite.sendJSON("POST", "/api/shifts/clock-in", "")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "employee_id_required")
+
+	w = suite.sendJSON("POST", "/api/shifts/clock-in", `{"employee_id": 1}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var entry model.TimeEntry
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &entry))
+	assert.Nil(suite.T(), entry.ShiftID, "смена начнется больше чем через час")
+
+	w = suite.sendJSON("POST", "/api/shifts/clock-in", `{"employee_id": 1}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "already_clocked_in")
+
+	w = suite.sendJSON("POST", "/api/shifts/clock-out", `{"employee_id": 1}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &entry))
+	assert.NotNil(suite.T(), entry.ClockOutAt)
+
+	w = suite.sendJSON("POST", "/api/shifts/clock-out", `{"employee_id": 1}`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "not_clocked_in")
+
+	// работник отмечается только сам
+	suite.createUser(`{"username": "worker", "password": "worker-pass", "role": "worker", "employee_id": 2}`)
+	token := suite.login("worker", "worker-pass")
+	req, _ := http.NewRequest("POST", "/api/shifts/clock-in", bytes.NewBufferString(`{"employee_id": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "clock_forbidden")
+
+	req, _ = http.NewRequest("GET", "/api/shifts?start_date="+day+"&end_date="+day, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &shifts))
+	assert.Empty(suite.T(), shifts)
+
+	w = suite.sendJSON("DELETE", "/api/shifts/"+strconv.Itoa(int(shift.ID)), "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	w = suite.sendJSON("DELETE", "/api/shifts/"+strconv.Itoa(int(shift.ID)), "")
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+func (suite *TestSuite) TestAttendanceReport() {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	// сотрудник 1 пропустил смену 4 марта и опоздал 5 марта, сотрудник 2 пришел вовремя
+	missed := model.Shift{EmployeeID: 1, StartsAt: at(4, 8, 0), EndsAt: at(4, 16, 0)}
+	late := model.Shift{EmployeeID: 1, StartsAt: at(5, 8, 0), EndsAt: at(5, 16, 0)}
+	onTime := model.Shift{EmployeeID: 2, StartsAt: at(5, 8, 0), EndsAt: at(5, 12, 0)}
+	for _, shift := range []*model.Shift{&missed, &late, &onTime} {
+		suite.Require().NoError(suite.db.Create(shift).Error)
+	}
+	entries := []model.TimeEntry{
+		{EmployeeID: 1, ShiftID: &late.ID, ClockInAt: at(5, 8, 20), ClockOutAt: ptr(at(5, 16, 0))},
+		{EmployeeID: 2, ShiftID: &onTime.ID, ClockInAt: at(5, 7, 50), ClockOutAt: ptr(at(5, 12, 0))},
+	}
+	suite.Require().NoError(suite.db.Create(&entries).Error)
+
+	w := suite.sendJSON("GET", "/api/reports/attendance?period=2024-03", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var report struct {
+		Attendance []service.EmployeeAttendance `json:"attendance"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	suite.Require().Len(report.Attendance, 2)
+
+	first := report.Attendance[0]
+	assert.Equal(suite.T(), 2, first.PlannedShifts)
+	assert.Equal(suite.T(), 16.0, first.PlannedHours)
+	assert.Equal(suite.T(), 7.67, first.WorkedHours)
+	assert.Equal(suite.T(), 1, first.DaysPresent)
+	assert.Equal(suite.T(), 1, first.MissedShifts)
+	assert.Equal(suite.T(), 1, first.LateShifts)
+	assert.Equal(suite.T(), []string{"2024-03-04"}, first.MissedDates)
+
+	second := report.Attendance[1]
+	assert.Equal(suite.T(), 4.17, second.WorkedHours)
+	assert.Equal(suite.T(), 0, second.MissedShifts)
+	assert.Equal(suite.T(), 0, second.LateShifts)
+
+	// выработка на час считается по отметкам
+	record := model.Farm{Date: at(5, 0, 0), CageID: 1, ChickenID: 1, HasEgg: true}
+	suite.Require().NoError(suite.db.Create(&record).Error)
+	w = suite.sendJSON("GET", "/api/reports/employee-egg-stats?start_date=2024-03-01&end_date=2024-03-31", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var eggStats struct {
+		Stats []service.EmployeeEggStats `json:"stats"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &eggStats))
+	suite.Require().Len(eggStats.Stats, 2)
+	assert.Equal(suite.T(), 7.67, eggStats.Stats[0].HoursWorked)
+	assert.Equal(suite.T(), 0.13, eggStats.Stats[0].EggsPerHour)
+
+	// пропущенная смена удерживается как пропуск, даже если пропуск еще и записан вручную
+	w = suite.sendJSON("POST", "/api/payroll/absences", `{"employee_id": 1, "date": "2024-03-04"}`)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	w = suite.sendJSON("POST", "/api/payroll/2024-03", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var payroll service.Payroll
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &payroll))
+	assert.Equal(suite.T(), 1, payroll.Payslips[0].AbsenceDays)
+	assert.Equal(suite.T(), 0, payroll.Payslips[1].AbsenceDays)
+}
+
+func (suite *TestSuite) TestPayrollSkipsWeekendAbsences() {
+	// 9 марта 2024 - суббота, 11 марта - понедельник
+	for _, date := range []string{"2024-03-09", "2024-03-11"} {
+		w := suite.sendJSON("POST", "/api/payroll/absences", `{"employee_id": 1, "date": "`+date+`"}`)
+		suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w := suite.sendJSON("POST", "/api/payroll/2024-03", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var payroll service.Payroll
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &payroll))
+	assert.Equal(suite.T(), 1, payroll.Payslips[0].AbsenceDays)
+	assert.Equal(suite.T(), 2380.95, payroll.Payslips[0].Deductions)
+}
+
+func (suite *TestSuite) TestPayrollProratesFromHireDate() {
+	employeeRepo := repository.NewEmployeeRepository(suite.db, suite.passports)
+	hired := model.Employee{FullName: "Сидоров Сидор Сидорович", PassportData: "3456 789012", Salary: 42000,
+		CreatedAt: time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC)}
+	suite.Require().NoError(employeeRepo.Create(&hired, seedAssignedFrom, false))
+	later := model.Employee{FullName: "Кузнецов Кузьма Кузьмич", PassportData: "4567 890123", Salary: 42000,
+		CreatedAt: time.Date(2024, 4, 2, 9, 0, 0, 0, time.UTC)}
+	suite.Require().NoError(employeeRepo.Create(&later, seedAssignedFrom, false))
+
+	// пропуск до приема не удерживается
+	for _, date := range []string{"2024-03-05", "2024-03-19"} {
+		w := suite.sendJSON("POST", "/api/payroll/absences", `{"employee_id": 3, "date": "`+date+`"}`)
+		suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w := suite.sendJSON("POST", "/api/payroll/2024-03", "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var payroll service.Payroll
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &payroll))
+	suite.Require().Len(payroll.Payslips, 3)
+
+	// с 18 марта 10 рабочих дней из 21, дневная ставка 2000
+	payslip := payroll.Payslips[2]
+	assert.Equal(suite.T(), hired.ID, payslip.EmployeeID)
+	assert.Equal(suite.T(), 20000.0, payslip.BaseSalary)
+	assert.Equal(suite.T(), 1, payslip.AbsenceDays)
+	assert.Equal(suite.T(), 2000.0, payslip.Deductions)
+	assert.Equal(suite.T(), 18000.0, payslip.Total)
+	suite.Require().Len(payslip.Lines, 2)
+	assert.Equal(suite.T(), 10.0, payslip.Lines[0].Quantity)
+	assert.Equal(suite.T(), 2000.0, payslip.Lines[0].Rate)
+}
+
+func (suite *TestSuite) TestLoginAndRoles() {
+	suite.createUser(`{"username": "manager", "password": "manager-pass", "role": "manager"}`)
+
+	// без токена закрытые маршруты недоступны
+	req, _ := http.NewRequest("GET", "/api/employees", nil)
+	w := httptest.NewRecorder()
+	suite.router.Engine.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "auth_required")
+
+	req, _ = http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(`{"username": "manager", "password": "wrong-pass"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "invalid_credentials")
+
+	token := suite.login("manager", "manager-pass")
+
+	req, _ = http.NewRequest("GET", "/api/employees", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	// удалять сотрудников может только администратор
+	req, _ = http.NewRequest("DELETE", "/api/employees/2", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "role_forbidden")
+
+	req, _ = http.NewRequest("GET", "/api/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"role":"manager"`)
+	assert.NotContains(suite.T(), w.Body.String(), "password")
+
+	req, _ = http.NewRequest("POST", "/api/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/employees", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "invalid_token")
+}
+
+func (suite *TestSuite) TestWorkerWritesOnlyOwnCages() {
+	// сотрудник 1 закреплен за клеткой 1
+	suite.createUser(`{"username": "worker", "password": "worker-pass", "role": "worker", "employee_id": 1}`)
+	token := suite.login("worker", "worker-pass")
+	today := time.Now().Format("2006-01-02")
+
+	// без отметки о приходе работник записи не ведет
+	req, _ := http.NewRequest("POST", "/api/farm-records",
+		bytes.NewBufferString(`{"date": "`+today+`", "cage_id": 1, "chicken_id": 1, "has_egg": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "not_on_shift")
+
+	req, _ = http.NewRequest("POST", "/api/shifts/clock-in", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "/api/farm-records",
+		bytes.NewBufferString(`{"date": "`+today+`", "cage_id": 1, "chicken_id": 1, "has_egg": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusCreated, w.Code)
+
+	// отметка сегодня не дает права вносить записи за день, когда работника не было на ферме
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	req, _ = http.NewRequest("POST", "/api/farm-records",
+		bytes.NewBufferString(`{"date": "`+yesterday+`", "cage_id": 1, "chicken_id": 1, "has_egg": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "not_on_site_that_day")
+
+	req, _ = http.NewRequest("PUT", "/api/collection-sheets/"+yesterday,
+		bytes.NewBufferString(`{"entries": [{"chicken_id": 1, "has_egg": true}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "not_on_site_that_day")
+
+	req, _ = http.NewRequest("POST", "/api/farm-records",
+		bytes.NewBufferString(`{"date": "`+today+`", "cage_id": 2, "chicken_id": 2, "has_egg": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "cage_forbidden")
+
+	req, _ = http.NewRequest("PUT", "/api/collection-sheets/"+today,
+		bytes.NewBufferString(`{"entries": [{"chicken_id": 1, "has_egg": true}, {"chicken_id": 2, "has_egg": true}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/employees", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+}
+
+func (suite *TestSuite) TestPasswordChangeRevokesSessions() {
+	suite.createUser(`{"username": "manager", "password": "manager-pass", "role": "manager"}`)
+	token := suite.login("manager", "manager-pass")
+
+	me := func() int {
+		req, _ := http.NewRequest("GET", "/api/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// правка без пароля сессии не трогает
+	w := suite.sendJSON("PUT", "/api/users/2", `{"username": "manager", "role": "manager", "can_view_passports": true}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), http.StatusOK, me())
+
+	w = suite.sendJSON("PUT", "/api/users/2", `{"username": "manager", "password": "new-manager-pass", "role": "manager"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), http.StatusUnauthorized, me())
+
+	var sessions int64
+	suite.Require().NoError(suite.db.Model(&model.Session{}).Where("user_id = ?", 2).Count(&sessions).Error)
+	assert.Zero(suite.T(), sessions)
+	suite.login("manager", "new-manager-pass")
+}
+
+func TestEnsureAdminRequiresPassword(t *testing.T) {
+	db, err := database.Open(config.DatabaseConfig{Driver: config.DriverSQLite, Path: ":memory:"})
+	assert.NoError(t, err)
+	_, err = migration.NewMigrator(db).Up()
+	assert.NoError(t, err)
+
+	authService := service.NewAuthService(repository.NewUserRepository(db), nil, time.Hour)
+
+	// на пустой базе без пароля войти было бы некому
+	created, err := authService.EnsureAdmin("admin", "")
+	assert.ErrorIs(t, err, service.ErrNoAdminPassword)
+	assert.False(t, created)
+
+	created, err = authService.EnsureAdmin("admin", "change-me")
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	// когда пользователи уже есть, пароль больше не нужен
+	created, err = authService.EnsureAdmin("admin", "")
+	assert.NoError(t, err)
+	assert.False(t, created)
+}
+
+func (suite *TestSuite) TestCreateUserValidation() {
+	req, _ := http.NewRequest("POST", "/api/users",
+		bytes.NewBufferString(`{"username": "worker", "password": "worker-pass", "role": "worker"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "employee_required")
+
+	req, _ = http.NewRequest("POST", "/api/users",
+		bytes.NewBufferString(`{"username": "admin", "password": "admin-pass", "role": "admin"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "username_taken")
+
+	// единственного администратора удалить нельзя
+	req, _ = http.NewRequest("DELETE", "/api/users/1", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "last_admin")
+}
+
+func (suite *TestSuite) TestPassportsEncryptedAndMasked() {
+	var raw model.Employee
+	suite.Require().NoError(suite.db.First(&raw, 1).Error)
+	assert.True(suite.T(), passport.IsEncrypted(raw.PassportData))
+	assert.NotContains(suite.T(), raw.PassportData, "567890")
+	assert.NotNil(suite.T(), raw.PassportHash)
+
+	req, _ := http.NewRequest("GET", "/api/employees/1", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"passport_data":"1234 ****90"`)
+
+	// право видеть паспорта выдается явно, даже администратору
+	req, _ = http.NewRequest("PUT", "/api/users/1",
+		bytes.NewBufferString(`{"username": "admin", "role": "admin", "can_view_passports": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/employees", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"passport_data":"1234 567890"`)
+}
+
+func (suite *TestSuite) TestPassportUniqueness() {
+	body := `{"full_name": "Сидоров Сидор", "passport_data": "1234 567890", "salary": 40000}`
+	req, _ := http.NewRequest("POST", "/api/employees", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "passport_taken")
+
+	// без паспорта обновление сохраняет прежний номер
+	req, _ = http.NewRequest("PUT", "/api/employees/2",
+		bytes.NewBufferString(`{"full_name": "Петров Петр", "salary": 47000, "cages": [2]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	employee, err := repository.NewEmployeeRepository(suite.db, suite.passports).GetByID(2)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "2345 678901", employee.PassportData)
+}
+
+func (suite *TestSuite) TestEncryptPassportsCommand() {
+	// сотрудник, сохраненный до появления шифрования
+	legacy := model.Employee{FullName: "Старый Сотрудник", PassportData: "5555 123456", Salary: 30000}
+	suite.Require().NoError(suite.db.Create(&legacy).Error)
+
+	employeeRepo := repository.NewEmployeeRepository(suite.db, suite.passports)
+	count, err := employeeRepo.CountUnencryptedPassports()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 1, count)
+
+	var out bytes.Buffer
+	err = runEncryptPassports(suite.db, config.SecurityConfig{PassportKey: testPassportKey}, &out)
+	suite.Require().NoError(err)
+	assert.Contains(suite.T(), out.String(), "encrypted 1 passports")
+
+	var raw model.Employee
+	suite.Require().NoError(suite.db.First(&raw, legacy.ID).Error)
+	assert.True(suite.T(), passport.IsEncrypted(raw.PassportData))
+
+	employee, err := employeeRepo.GetByPassport("5555 123456")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), legacy.ID, employee.ID)
+	assert.Equal(suite.T(), "5555 123456", employee.PassportData)
+
+	out.Reset()
+	suite.Require().NoError(runEncryptPassports(suite.db, config.SecurityConfig{PassportKey: testPassportKey}, &out))
+	assert.Contains(suite.T(), out.String(), "encrypted 0 passports")
+
+	// старая строка с паспортом, уже принадлежащим другому сотруднику, не шифруется молча
+	duplicate := model.Employee{FullName: "Двойник", PassportData: "1234 567890", Salary: 30000}
+	suite.Require().NoError(suite.db.Create(&duplicate).Error)
+	err = runEncryptPassports(suite.db, config.SecurityConfig{PassportKey: testPassportKey}, &out)
+	suite.Require().Error(err)
+	assert.Contains(suite.T(), err.Error(), fmt.Sprintf("employee %d has the same passport", duplicate.ID))
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+server:
+  addr: ":9000"
+  static_dir: "/srv/frontend"
+database:
+  path: "/var/lib/farm.db"
+cors:
+  allow_origins: ["https://farm.example.com"]
+`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	env := map[string]string{
+		config.EnvConfigPath: path,
+		config.EnvDBPath:     "/tmp/env.db",
+		config.EnvAddr:       ":9100",
+	}
+	cfg, args, err := config.Load([]string{"-addr", "127.0.0.1:9200", "migrate"}, func(key string) string {
+		return env[key]
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"migrate"}, args)
+	assert.Equal(t, "127.0.0.1:9200", cfg.Server.Addr)
+	assert.Equal(t, "/srv/frontend", cfg.Server.StaticDir)
+	assert.Equal(t, "/tmp/env.db", cfg.Database.Path)
+	assert.Equal(t, []string{"https://farm.example.com"}, cfg.CORS.AllowOrigins)
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, _, err := config.Load(nil, func(string) string { return "" })
+	assert.NoError(t, err)
+	assert.Equal(t, config.Default(), cfg)
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	env := map[string]string{config.EnvCORSOrigins: "localhost:3000"}
+	_, _, err := config.Load([]string{"-addr", "8080", "-db", ""}, func(key string) string {
+		return env[key]
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server.addr")
+	assert.Contains(t, err.Error(), "database.path")
+	assert.Contains(t, err.Error(), "cors.allow_origins")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("server:\n  adr: \":9000\"\n"), 0o600))
+	_, _, err = config.Load([]string{"-config", path}, func(string) string { return "" })
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "adr")
+}
+
+func TestLoadConfigPostgres(t *testing.T) {
+	env := map[string]string{
+		config.EnvDBDriver: config.DriverPostgres,
+		config.EnvDBDSN:    "host=localhost dbname=farm",
+	}
+	cfg, _, err := config.Load([]string{"-db-dsn", "host=db dbname=farm"}, func(key string) string {
+		return env[key]
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, config.DriverPostgres, cfg.Database.Driver)
+	assert.Equal(t, "host=db dbname=farm", cfg.Database.DSN)
+
+	_, _, err = config.Load([]string{"-db-driver", config.DriverPostgres}, func(string) string { return "" })
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "database.dsn")
+
+	_, _, err = config.Load([]string{"-db-driver", "mysql"}, func(string) string { return "" })
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "database.driver")
+}
+
+// TestConcurrentChickenCreates селит кур в одну клетку параллельными запросами:
+// мест должно хватить ровно на вместимость клетки, остальные запросы получают 409
+func TestConcurrentChickenCreates(t *testing.T) {
+	db, err := database.Open(config.DatabaseConfig{Driver: config.DriverSQLite, Path: filepath.Join(t.TempDir(), "farm.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testConcurrentChickenCreates(t, db)
+}
+
+// TestConcurrentChickenCreatesPostgres - то же на PostgreSQL, где транзакции идут параллельно
+// и клетку защищает блокировка ее строки
+func TestConcurrentChickenCreatesPostgres(t *testing.T) {
+	dsn := postgresDSN(t)
+
+	db, err := database.Open(config.DatabaseConfig{Driver: config.DriverPostgres, DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("DROP SCHEMA public CASCADE").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("CREATE SCHEMA public").Error; err != nil {
+		t.Fatal(err)
+	}
+	testConcurrentChickenCreates(t, db)
+}
+
+func testConcurrentChickenCreates(t *testing.T, db *gorm.DB) {
+	if _, err := migration.NewMigrator(db).Up(); err != nil {
+		t.Fatal(err)
+	}
+	cage := model.Cage{Number: 1, Capacity: 3}
+	if err := db.Create(&cage).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	authService, token := loginAdmin(t, db)
+	chickenService := service.NewChickenService(repository.NewChickenRepository(db), repository.NewFarmRepository(db))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(controller.Authenticate(authService))
+	controller.NewChickenController(chickenService).RegisterRoutes(router)
+	farmRepo, chickenRepo := repository.NewFarmRepository(db), repository.NewChickenRepository(db)
+	employeeRepo := repository.NewEmployeeRepository(db, newTestPassportCipher(t))
+	controller.NewCageController(service.NewCageService(farmRepo, chickenRepo, employeeRepo)).RegisterRoutes(router)
+
+	const requests = 10
+	codes := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"cage_id": %d, "weight": 2.5, "age": 12, "egg_per_month": 20, "breed": "Леггорн"}`, cage.ID)
+			req, _ := http.NewRequest("POST", "/api/chickens", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}(i)
+	}
+	wg.Wait()
+
+	created, conflicts := 0, 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		}
+	}
+	assert.Equal(t, cage.Capacity, created)
+	assert.Equal(t, requests-cage.Capacity, conflicts)
+
+	var slots []int
+	assert.NoError(t, db.Model(&model.Chicken{}).Where("cage_id = ?", cage.ID).Order("slot").Pluck("slot", &slots).Error)
+	assert.Equal(t, []int{1, 2, 3}, slots)
+
+	// удаление пустой клетки наперегонки с заселением: либо клетка осталась, либо в ней никого нет
+	empty := model.Cage{Number: 2, Capacity: requests}
+	if err := db.Create(&empty).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/cages/%d", empty.ID), nil)
+			if i > 0 {
+				body := fmt.Sprintf(`{"cage_id": %d, "weight": 2.5, "age": 12, "egg_per_month": 20, "breed": "Леггорн"}`, empty.ID)
+				req, _ = http.NewRequest("POST", "/api/chickens", strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+		}(i)
+	}
+	wg.Wait()
+
+	var cages, orphans int64
+	assert.NoError(t, db.Model(&model.Cage{}).Where("id = ?", empty.ID).Count(&cages).Error)
+	assert.NoError(t, db.Model(&model.Chicken{}).Where("cage_id = ?", empty.ID).Count(&orphans).Error)
+	if cages == 0 {
+		assert.Zero(t, orphans)
+	}
+	// при исключительном закреплении из одновременных заявок на одну клетку проходит одна
+	if err := farmRepo.UpdateConfigParam(model.ConfigCageAssignmentPolicy, model.CageAssignmentExclusive); err != nil {
+		t.Fatal(err)
+	}
+	controller.NewEmployeeController(service.NewEmployeeService(employeeRepo, farmRepo)).RegisterRoutes(router)
+	codes = make([]int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"full_name": "Сотрудник %d", "passport_data": "4510 %06d", "salary": 40000, "cages": [%d]}`, i, i, cage.ID)
+			req, _ := http.NewRequest("POST", "/api/employees", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}(i)
+	}
+	wg.Wait()
+
+	var caretakers int64
+	assert.NoError(t, db.Model(&model.EmployeeCage{}).Where("cage_id = ? AND valid_to IS NULL", cage.ID).Count(&caretakers).Error)
+	assert.Equal(t, int64(1), caretakers)
+	created = 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			created++
+		}
+	}
+	assert.Equal(t, 1, created, codes)
+}
+
+func TestMigrateCommand(t *testing.T) {
+	db, err := database.Open(config.DatabaseConfig{Driver: config.DriverSQLite, Path: ":memory:"})
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, runMigrate(db, []string{"up"}, &out))
+	assert.Contains(t, out.String(), "applied 0001 baseline")
+	assert.Contains(t, out.String(), "applied 0002 users")
+	assert.True(t, db.Migrator().HasTable("chickens"))
+
+	out.Reset()
+	assert.NoError(t, runMigrate(db, []string{"up"}, &out))
+	assert.Contains(t, out.String(), "schema is up to date")
+
+	// по умолчанию откатывается одна последняя миграция
+	out.Reset()
+	assert.NoError(t, runMigrate(db, []string{"down"}, &out))
+	assert.Equal(t, 1, strings.Count(out.String(), "rolled back"))
+
+	out.Reset()
+	assert.NoError(t, runMigrate(db, []string{"status"}, &out))
+	assert.Equal(t, 1, strings.Count(out.String(), "pending"))
+
+	out.Reset()
+	assert.NoError(t, runMigrate(db, []string{"down", "100"}, &out))
+	assert.Contains(t, out.String(), "rolled back 0001 baseline")
+	assert.False(t, db.Migrator().HasTable("chickens"))
+
+	assert.Error(t, runMigrate(db, []string{"sideways"}, &out))
+	assert.Error(t, runMigrate(db, []string{"down", "0"}, &out))
+}
+
+// TestMigrationDownKeepsIndexes откатывает схему до версии 2: удаление столбцов не должно
+// терять индексы таблиц (в SQLite Migrator().DropColumn пересоздает таблицу без них)
+func TestMigrationDownKeepsIndexes(t *testing.T) {
+	db, err := database.Open(config.DatabaseConfig{Driver: config.DriverSQLite, Path: ":memory:"})
+	assert.NoError(t, err)
+
+	migrator := migration.NewMigrator(db)
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	_, err = migrator.Down(len(statuses) - 2)
+	assert.NoError(t, err)
+
+	assert.True(t, db.Migrator().HasIndex("users", "idx_users_username"))
+	assert.True(t, db.Migrator().HasIndex("employees", "idx_employees_passport_data"))
+}
+
+func TestMigrationsAdoptAutoMigratedDatabase(t *testing.T) {
+	db, err := database.Open(config.DatabaseConfig{Driver: config.DriverSQLite, Path: ":memory:"})
+	assert.NoError(t, err)
+
+	// база, созданная до появления миграций: таблицы базовой схемы есть, записей о версиях нет
+	migrator := migration.NewMigrator(db)
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	_, err = migrator.Down(len(statuses) - 1)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec("DELETE FROM schema_migrations").Error)
+
+	assert.NoError(t, db.Exec("INSERT INTO cages (number, capacity, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		7, 1, time.Now(), time.Now()).Error)
+	assignedAt := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+	assert.NoError(t, db.Exec("INSERT INTO employee_cages (employee_id, cage_id, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		1, 1, assignedAt, assignedAt).Error)
+	assert.NoError(t, db.Exec("INSERT INTO chickens (cage_id, weight, age, egg_per_month, breed, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		1, 2.5, 12, 25, "Леггорн", assignedAt, assignedAt).Error)
+
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.Len(t, applied, len(statuses))
+	assert.Equal(t, "baseline", applied[0].Name)
+
+	var cage model.Cage
+	assert.NoError(t, db.Where("number = ?", 7).First(&cage).Error)
+
+	// старое закрепление действует с дня своего создания
+	var assignment model.EmployeeCage
+	assert.NoError(t, db.First(&assignment).Error)
+	assert.True(t, assignment.ValidFrom.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)))
+	assert.Nil(t, assignment.ValidTo)
+
+	// дата вылупления оценивается по возрасту на день добавления курицы
+	var chicken model.Chicken
+	assert.NoError(t, db.First(&chicken).Error)
+	assert.True(t, chicken.HatchDate.Equal(time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 1, chicken.Slot)
+
+	// нынешний вес становится первым взвешиванием
+	var weighIn model.WeighIn
+	assert.NoError(t, db.Where("chicken_id = ?", chicken.ID).First(&weighIn).Error)
+	assert.Equal(t, 2.5, weighIn.Weight)
+	assert.True(t, weighIn.Date.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)))
+
+	indexes, err := db.Migrator().GetIndexes(&model.Cage{})
+	assert.NoError(t, err)
+	unique := 0
+	for _, index := range indexes {
+		if columns := index.Columns(); len(columns) == 1 && columns[0] == "number" {
+			unique++
+		}
+	}
+	assert.Equal(t, 1, unique)
+
+	assert.True(t, db.Migrator().HasIndex(&model.Chicken{}, "idx_chickens_cage_id"))
+	assert.True(t, db.Migrator().HasIndex(&model.Farm{}, "idx_farm_records_date_cage_id"))
+	assert.True(t, db.Migrator().HasIndex(&model.Farm{}, "idx_farm_records_chicken_id"))
+	assert.True(t, db.Migrator().HasIndex(&model.EmployeeCage{}, "idx_employee_cages_employee_id"))
+}
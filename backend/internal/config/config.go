@@ -0,0 +1,301 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"chicken-farm/internal/passport"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	CORS     CORSConfig     `yaml:"cors"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Security SecurityConfig `yaml:"security"`
+}
+
+type ServerConfig struct {
+	Addr      string `yaml:"addr"`       // адрес, на котором слушает HTTP-сервер
+	StaticDir string `yaml:"static_dir"` // собранный фронтенд
+}
+
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"` // sqlite или postgres
+	Path   string `yaml:"path"`   // файл SQLite
+	DSN    string `yaml:"dsn"`    // строка подключения к PostgreSQL
+}
+
+// Поддерживаемые драйверы базы данных
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+type CORSConfig struct {
+	AllowOrigins []string `yaml:"allow_origins"`
+}
+
+type AuthConfig struct {
+	SessionTTL time.Duration `yaml:"session_ttl"` // время жизни токена после входа
+	// учетная запись администратора, которая создается при первом запуске на пустой базе
+	AdminUsername string `yaml:"admin_username"`
+	AdminPassword string `yaml:"admin_password"`
+}
+
+type SecurityConfig struct {
+	PassportKey string `yaml:"passport_key"` // ключ шифрования паспортов, 32 байта в base64
+}
+
+// Переменные окружения, переопределяющие значения из файла
+const (
+	EnvConfigPath  = "CHICKEN_FARM_CONFIG"
+	EnvAddr        = "CHICKEN_FARM_ADDR"
+	EnvStaticDir   = "CHICKEN_FARM_STATIC_DIR"
+	EnvDBDriver    = "CHICKEN_FARM_DB_DRIVER"
+	EnvDBPath      = "CHICKEN_FARM_DB_PATH"
+	EnvDBDSN       = "CHICKEN_FARM_DB_DSN"
+	EnvCORSOrigins = "CHICKEN_FARM_CORS_ORIGINS"
+	EnvSessionTTL  = "CHICKEN_FARM_SESSION_TTL"
+	EnvAdminUser   = "CHICKEN_FARM_ADMIN_USERNAME"
+	EnvAdminPass   = "CHICKEN_FARM_ADMIN_PASSWORD"
+	EnvPassportKey = "CHICKEN_FARM_PASSPORT_KEY"
+)
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:      ":8080",
+			StaticDir: "./web/build",
+		},
+		Database: DatabaseConfig{
+			Driver: DriverSQLite,
+			Path:   "chicken_farm.db",
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"http://localhost:3000", "http://127.0.0.1:3000"},
+		},
+		Auth: AuthConfig{
+			SessionTTL:    24 * time.Hour,
+			AdminUsername: "admin",
+		},
+	}
+}
+
+// Load собирает конфигурацию по возрастанию приоритета: значения по умолчанию, YAML-файл,
+// переменные окружения, флаги командной строки. Возвращает аргументы, оставшиеся после флагов.
+func Load(args []string, getenv func(string) string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("chicken-farm", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to YAML config file (env "+EnvConfigPath+")")
+	addr := flags.String("addr", "", "HTTP listen address (env "+EnvAddr+")")
+	staticDir := flags.String("static-dir", "", "directory with the built frontend (env "+EnvStaticDir+")")
+	dbDriver := flags.String("db-driver", "", "database driver: sqlite or postgres (env "+EnvDBDriver+")")
+	dbPath := flags.String("db", "", "SQLite database file (env "+EnvDBPath+")")
+	dbDSN := flags.String("db-dsn", "", "PostgreSQL connection string (env "+EnvDBDSN+")")
+	corsOrigins := flags.String("cors-origins", "", "comma-separated list of allowed CORS origins (env "+EnvCORSOrigins+")")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+
+	path := *configPath
+	if path == "" {
+		path = getenv(EnvConfigPath)
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(getenv); err != nil {
+		return nil, nil, err
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "static-dir":
+			cfg.Server.StaticDir = *staticDir
+		case "db-driver":
+			cfg.Database.Driver = *dbDriver
+		case "db":
+			cfg.Database.Path = *dbPath
+		case "db-dsn":
+			cfg.Database.DSN = *dbDSN
+		case "cors-origins":
+			cfg.CORS.AllowOrigins = splitList(*corsOrigins)
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	// опечатка в имени параметра не должна молча игнорироваться
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	if value := getenv(EnvAddr); value != "" {
+		c.Server.Addr = value
+	}
+	if value := getenv(EnvStaticDir); value != "" {
+		c.Server.StaticDir = value
+	}
+	if value := getenv(EnvDBDriver); value != "" {
+		c.Database.Driver = value
+	}
+	if value := getenv(EnvDBPath); value != "" {
+		c.Database.Path = value
+	}
+	if value := getenv(EnvDBDSN); value != "" {
+		c.Database.DSN = value
+	}
+	if value := getenv(EnvCORSOrigins); value != "" {
+		c.CORS.AllowOrigins = splitList(value)
+	}
+	if value := getenv(EnvSessionTTL); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvSessionTTL, err)
+		}
+		c.Auth.SessionTTL = ttl
+	}
+	if value := getenv(EnvAdminUser); value != "" {
+		c.Auth.AdminUsername = value
+	}
+	if value := getenv(EnvAdminPass); value != "" {
+		c.Auth.AdminPassword = value
+	}
+	if value := getenv(EnvPassportKey); value != "" {
+		c.Security.PassportKey = value
+	}
+
+	return nil
+}
+
+// Validate проверяет все параметры сразу и перечисляет все найденные ошибки
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := validateAddr(c.Server.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("server.addr: %w", err))
+	}
+
+	switch c.Database.Driver {
+	case DriverSQLite:
+		if strings.TrimSpace(c.Database.Path) == "" {
+			errs = append(errs, errors.New("database.path: must not be empty"))
+		}
+	case DriverPostgres:
+		if strings.TrimSpace(c.Database.DSN) == "" {
+			errs = append(errs, errors.New("database.dsn: must not be empty for the postgres driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("database.driver: %q is not supported, use %q or %q",
+			c.Database.Driver, DriverSQLite, DriverPostgres))
+	}
+
+	for _, origin := range c.CORS.AllowOrigins {
+		if err := validateOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("cors.allow_origins: %q: %w", origin, err))
+		}
+	}
+
+	if c.Auth.SessionTTL <= 0 {
+		errs = append(errs, errors.New("auth.session_ttl: must be positive"))
+	}
+
+	if c.Auth.AdminPassword != "" {
+		if strings.TrimSpace(c.Auth.AdminUsername) == "" {
+			errs = append(errs, errors.New("auth.admin_username: must not be empty when admin_password is set"))
+		}
+		if len(c.Auth.AdminPassword) < 8 {
+			errs = append(errs, errors.New("auth.admin_password: must be at least 8 characters long"))
+		}
+	}
+
+	// пустой ключ допустим для команды migrate, сервер без ключа не запустится
+	if c.Security.PassportKey != "" {
+		if _, err := passport.ParseKey(c.Security.PassportKey); err != nil {
+			errs = append(errs, fmt.Errorf("security.passport_key: %w", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func validateAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.New("must be in the form host:port, for example \":8080\"")
+	}
+
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 0 || number > 65535 {
+		return errors.New("port must be a number between 0 and 65535")
+	}
+
+	return nil
+}
+
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an http(s) origin such as http://localhost:3000")
+	}
+
+	if u.Path != "" && u.Path != "/" {
+		return errors.New("must not contain a path")
+	}
+
+	return nil
+}
+
+func splitList(value string) []string {
+	parts := strings.Split(value, ",")
+	items := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"slices"
+	"strings"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	userContextKey      = "user"
+	authErrorContextKey = "auth_error"
+)
+
+// Наборы ролей, которые контроллеры указывают для своих маршрутов
+var (
+	anyRole     = []model.Role{model.RoleAdmin, model.RoleManager, model.RoleWorker}
+	managerRole = []model.Role{model.RoleAdmin, model.RoleManager}
+	adminRole   = []model.Role{model.RoleAdmin}
+)
+
+// Authenticate определяет пользователя по заголовку Authorization: Bearer <token>.
+// Запрос без токена или с недействительным токеном пропускается дальше: открытые маршруты,
+// например вход, должны работать и со старым токеном, а закрытые проверяет RequireRole.
+func Authenticate(authService *service.AuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token := bearerToken(ctx); token != "" {
+			user, err := authService.Authenticate(token)
+			if err != nil {
+				ctx.Set(authErrorContextKey, err)
+			} else {
+				ctx.Set(userContextKey, user)
+			}
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireRole пропускает только пользователей с одной из перечисленных ролей
+func RequireRole(roles ...model.Role) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user := currentUser(ctx)
+		if user == nil {
+			var err error = service.ErrAuthRequired
+			if authErr, ok := ctx.Get(authErrorContextKey); ok {
+				err = authErr.(error)
+			}
+			respondError(ctx, err)
+			ctx.Abort()
+			return
+		}
+
+		if !slices.Contains(roles, user.Role) {
+			respondError(ctx, service.ErrRoleForbidden)
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// currentUser возвращает пользователя, найденного Authenticate, или nil
+func currentUser(ctx *gin.Context) *model.User {
+	value, ok := ctx.Get(userContextKey)
+	if !ok {
+		return nil
+	}
+	user, _ := value.(*model.User)
+	return user
+}
+
+func bearerToken(ctx *gin.Context) string {
+	header := ctx.GetHeader("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"net/http"
+
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthController struct {
+	authService *service.AuthService
+}
+
+func NewAuthController(authService *service.AuthService) *AuthController {
+	return &AuthController{
+		authService: authService,
+	}
+}
+
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (c *AuthController) RegisterRoutes(router *gin.Engine) {
+	auth := router.Group("/api/auth")
+	{
+		auth.POST("/login", c.Login)
+		auth.POST("/logout", RequireRole(anyRole...), c.Logout)
+		auth.GET("/me", RequireRole(anyRole...), c.Me)
+	}
+}
+
+func (c *AuthController) Login(ctx *gin.Context) {
+	var request loginRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	result, err := c.authService.Login(request.Username, request.Password)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (c *AuthController) Logout(ctx *gin.Context) {
+	if err := c.authService.Logout(bearerToken(ctx)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+func (c *AuthController) Me(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, currentUser(ctx))
+}
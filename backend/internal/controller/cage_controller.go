@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CageController struct {
+	cageService *service.CageService
+}
+
+func NewCageController(cageService *service.CageService) *CageController {
+	return &CageController{
+		cageService: cageService,
+	}
+}
+
+type reassignCageRequest struct {
+	FromEmployeeID *uint `json:"from_employee_id" binding:"omitempty,gt=0"` // не задан - снять со всех
+	ToEmployeeID   uint  `json:"to_employee_id" binding:"required,gt=0"`
+}
+
+func (c *CageController) RegisterRoutes(router *gin.Engine) {
+	cages := router.Group("/api/cages")
+	{
+		cages.GET("", RequireRole(anyRole...), c.GetAllCages)
+		cages.GET("/empty", RequireRole(anyRole...), c.GetEmptyCages)
+		cages.GET("/:id", RequireRole(anyRole...), c.GetCageByID)
+		cages.POST("", RequireRole(managerRole...), c.CreateCage)
+		cages.PUT("/:id", RequireRole(managerRole...), c.UpdateCage)
+		cages.DELETE("/:id", RequireRole(managerRole...), c.DeleteCage)
+		cages.POST("/:id/reassign", RequireRole(managerRole...), c.ReassignCage)
+	}
+}
+
+func (c *CageController) GetAllCages(ctx *gin.Context) {
+	cages, err := c.cageService.GetAllCages()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cages)
+}
+
+func (c *CageController) GetEmptyCages(ctx *gin.Context) {
+	cages, err := c.cageService.GetEmptyCages()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cages)
+}
+
+func (c *CageController) GetCageByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	cage, err := c.cageService.GetCageDetails(uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	maskPassports(ctx, cage.Employees)
+	ctx.JSON(http.StatusOK, cage)
+}
+
+func (c *CageController) CreateCage(ctx *gin.Context) {
+	var cage model.Cage
+	if err := ctx.ShouldBindJSON(&cage); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	if err := c.cageService.CreateCage(&cage); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, cage)
+}
+
+func (c *CageController) UpdateCage(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	var cage model.Cage
+	if err := ctx.ShouldBindJSON(&cage); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	cage.ID = uint(id)
+	if err := c.cageService.UpdateCage(&cage); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cage)
+}
+
+func (c *CageController) DeleteCage(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	if err := c.cageService.DeleteCage(uint(id)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "cage deleted successfully"})
+}
+
+func (c *CageController) ReassignCage(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	var request reassignCageRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	cage, err := c.cageService.ReassignCage(uint(id), request.FromEmployeeID, request.ToEmployeeID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	maskPassports(ctx, cage.Employees)
+	ctx.JSON(http.StatusOK, cage)
+}
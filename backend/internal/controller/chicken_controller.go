@@ -3,8 +3,10 @@ package controller
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
 	"chicken-farm/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -20,40 +22,93 @@ func NewChickenController(chickenService *service.ChickenService) *ChickenContro
 	}
 }
 
+type weighInRequest struct {
+	ChickenID uint    `json:"chicken_id"`                            // только в пакетной записи
+	Date      string  `json:"date"`                                  // в формате YYYY-MM-DD, по умолчанию сегодня
+	Weight    float64 `json:"weight" binding:"required,gt=0,lte=15"` // вес в килограммах
+}
+
+func (r *weighInRequest) toModel() (model.WeighIn, error) {
+	weighIn := model.WeighIn{ChickenID: r.ChickenID, Weight: r.Weight}
+	if r.Date != "" {
+		date, err := time.Parse(dateLayout, r.Date)
+		if err != nil {
+			return weighIn, err
+		}
+		weighIn.Date = date
+	}
+	return weighIn, nil
+}
+
+type weighInBatchRequest struct {
+	WeighIns []weighInRequest `json:"weigh_ins" binding:"required,min=1,dive"`
+}
+
+// chickenUpdateResponse - курица и предупреждения о потере веса, если правка изменила вес
+type chickenUpdateResponse struct {
+	model.Chicken
+	WeightLossAlerts []service.WeightLossAlert `json:"weight_loss_alerts"`
+}
+
 func (c *ChickenController) RegisterRoutes(router *gin.Engine) {
 	chickens := router.Group("/api/chickens")
 	{
-		chickens.GET("", c.GetAllChickens)
-		chickens.GET("/:id", c.GetChickenByID)
-		chickens.POST("", c.CreateChicken)
-		chickens.PUT("/:id", c.UpdateChicken)
-		chickens.DELETE("/:id", c.DeleteChicken)
-		chickens.GET("/low-productivity", c.GetChickensWithLowProductivity)
-		chickens.GET("/most-productive", c.GetMostProductiveChicken)
-		chickens.GET("/avg-eggs", c.GetAvgEggsByWeightAndAge)
+		chickens.GET("", RequireRole(anyRole...), c.GetAllChickens)
+		chickens.GET("/:id", RequireRole(anyRole...), c.GetChickenByID)
+		chickens.POST("", RequireRole(managerRole...), c.CreateChicken)
+		chickens.PUT("/:id", RequireRole(managerRole...), c.UpdateChicken)
+		chickens.DELETE("/:id", RequireRole(managerRole...), c.DeleteChicken)
+		chickens.GET("/low-productivity", RequireRole(anyRole...), c.GetChickensWithLowProductivity)
+		chickens.GET("/most-productive", RequireRole(anyRole...), c.GetMostProductiveChicken)
+		chickens.GET("/avg-eggs", RequireRole(anyRole...), c.GetAvgEggsByWeightAndAge)
+		chickens.POST("/weights", RequireRole(managerRole...), c.RecordWeighIns)
+		chickens.GET("/:id/weights", RequireRole(anyRole...), c.GetWeighIns)
+		chickens.POST("/:id/weights", RequireRole(managerRole...), c.RecordWeighIn)
 	}
 }
 
 func (c *ChickenController) GetAllChickens(ctx *gin.Context) {
-	chickens, err := c.chickenService.GetAllChickens()
+	list, ok := listQuery(ctx, repository.ChickenSortColumns)
+	if !ok {
+		return
+	}
+
+	filter := repository.ChickenFilter{Breed: ctx.Query("breed")}
+	if value := ctx.Query("cage_id"); value != "" {
+		cageID, err := strconv.Atoi(value)
+		if err != nil || cageID <= 0 {
+			respondBadRequest(ctx, "invalid cage_id")
+			return
+		}
+		filter.CageID = uint(cageID)
+	}
+	if filter.MinWeight, ok = floatQuery(ctx, "min_weight"); !ok {
+		return
+	}
+	if filter.MaxWeight, ok = floatQuery(ctx, "max_weight"); !ok {
+		return
+	}
+
+	chickens, total, err := c.chickenService.ListChickens(filter, list)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
+	setPageHeaders(ctx, list, total)
 	ctx.JSON(http.StatusOK, chickens)
 }
 
 func (c *ChickenController) GetChickenByID(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		respondBadRequest(ctx, "invalid ID")
 		return
 	}
 
 	chicken, err := c.chickenService.GetChickenByID(uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "chicken not found"})
+		respondError(ctx, err)
 		return
 	}
 
@@ -63,12 +118,12 @@ func (c *ChickenController) GetChickenByID(ctx *gin.Context) {
 func (c *ChickenController) CreateChicken(ctx *gin.Context) {
 	var chicken model.Chicken
 	if err := ctx.ShouldBindJSON(&chicken); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(ctx, err)
 		return
 	}
 
 	if err := c.chickenService.CreateChicken(&chicken); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -78,34 +133,35 @@ func (c *ChickenController) CreateChicken(ctx *gin.Context) {
 func (c *ChickenController) UpdateChicken(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		respondBadRequest(ctx, "invalid ID")
 		return
 	}
 
 	var chicken model.Chicken
 	if err := ctx.ShouldBindJSON(&chicken); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(ctx, err)
 		return
 	}
 
 	chicken.ID = uint(id)
-	if err := c.chickenService.UpdateChicken(&chicken); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	alerts, err := c.chickenService.UpdateChicken(&chicken)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, chicken)
+	ctx.JSON(http.StatusOK, chickenUpdateResponse{Chicken: chicken, WeightLossAlerts: alerts})
 }
 
 func (c *ChickenController) DeleteChicken(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		respondBadRequest(ctx, "invalid ID")
 		return
 	}
 
 	if err := c.chickenService.DeleteChicken(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -115,7 +171,7 @@ func (c *ChickenController) DeleteChicken(ctx *gin.Context) {
 func (c *ChickenController) GetChickensWithLowProductivity(ctx *gin.Context) {
 	chickens, err := c.chickenService.GetChickensWithLowProductivity()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -125,7 +181,7 @@ func (c *ChickenController) GetChickensWithLowProductivity(ctx *gin.Context) {
 func (c *ChickenController) GetMostProductiveChicken(ctx *gin.Context) {
 	chicken, err := c.chickenService.GetMostProductiveChicken()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -138,21 +194,117 @@ func (c *ChickenController) GetAvgEggsByWeightAndAge(ctx *gin.Context) {
 
 	weight, err := strconv.ParseFloat(weightStr, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid weight"})
+		respondBadRequest(ctx, "invalid weight")
 		return
 	}
 
 	age, err := strconv.Atoi(ageStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid age"})
+		respondBadRequest(ctx, "invalid age")
 		return
 	}
 
 	avgEggs, err := c.chickenService.GetAvgEggsByWeightAndAge(weight, age)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"weight": weight, "age": age, "avg_eggs": avgEggs})
 }
+
+// RecordWeighIn записывает одно взвешивание курицы
+func (c *ChickenController) RecordWeighIn(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	var request weighInRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	request.ChickenID = uint(id)
+	weighIn, err := request.toModel()
+	if err != nil {
+		respondBadRequest(ctx, "invalid date")
+		return
+	}
+
+	result, err := c.chickenService.RecordWeighIns([]model.WeighIn{weighIn})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, result)
+}
+
+// RecordWeighIns записывает взвешивания нескольких кур за один запрос
+func (c *ChickenController) RecordWeighIns(ctx *gin.Context) {
+	var request weighInBatchRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	weighIns := make([]model.WeighIn, 0, len(request.WeighIns))
+	for _, item := range request.WeighIns {
+		if item.ChickenID == 0 {
+			respondBadRequest(ctx, "chicken_id is required")
+			return
+		}
+		weighIn, err := item.toModel()
+		if err != nil {
+			respondBadRequest(ctx, "invalid date")
+			return
+		}
+		weighIns = append(weighIns, weighIn)
+	}
+
+	result, err := c.chickenService.RecordWeighIns(weighIns)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, result)
+}
+
+// GetWeighIns возвращает историю веса курицы; start_date и end_date необязательны
+func (c *ChickenController) GetWeighIns(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	var startDate, endDate *time.Time
+	if value := ctx.Query("start_date"); value != "" {
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			respondBadRequest(ctx, "invalid start_date")
+			return
+		}
+		startDate = &date
+	}
+	if value := ctx.Query("end_date"); value != "" {
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			respondBadRequest(ctx, "invalid end_date")
+			return
+		}
+		endDate = &date
+	}
+
+	weighIns, err := c.chickenService.GetWeighIns(uint(id), startDate, endDate)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, weighIns)
+}
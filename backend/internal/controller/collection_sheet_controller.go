@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CollectionSheetController struct {
+	collectionSheetService *service.CollectionSheetService
+}
+
+func NewCollectionSheetController(collectionSheetService *service.CollectionSheetService) *CollectionSheetController {
+	return &CollectionSheetController{
+		collectionSheetService: collectionSheetService,
+	}
+}
+
+type collectionSheetRequest struct {
+	Entries []service.CollectionSheetMark `json:"entries" binding:"required,dive"`
+}
+
+func (c *CollectionSheetController) RegisterRoutes(router *gin.Engine) {
+	sheets := router.Group("/api/collection-sheets")
+	{
+		sheets.GET("/:date", RequireRole(anyRole...), c.GetSheet)
+		sheets.PUT("/:date", RequireRole(anyRole...), c.SaveSheet)
+	}
+}
+
+func (c *CollectionSheetController) GetSheet(ctx *gin.Context) {
+	date, err := time.Parse(dateLayout, ctx.Param("date"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid date")
+		return
+	}
+
+	sheet, err := c.collectionSheetService.GetSheet(date)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sheet)
+}
+
+func (c *CollectionSheetController) SaveSheet(ctx *gin.Context) {
+	date, err := time.Parse(dateLayout, ctx.Param("date"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid date")
+		return
+	}
+
+	var request collectionSheetRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	sheet, err := c.collectionSheetService.SaveSheet(currentUser(ctx), date, request.Entries)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sheet)
+}
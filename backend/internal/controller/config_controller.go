@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"net/http"
+
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConfigController struct {
+	configService *service.ConfigService
+}
+
+func NewConfigController(configService *service.ConfigService) *ConfigController {
+	return &ConfigController{
+		configService: configService,
+	}
+}
+
+type configParamRequest struct {
+	Value string `json:"value" binding:"required"`
+}
+
+func (c *ConfigController) RegisterRoutes(router *gin.Engine) {
+	config := router.Group("/api/config")
+	{
+		config.GET("", RequireRole(managerRole...), c.GetAllParams)
+		config.GET("/:key", RequireRole(managerRole...), c.GetParam)
+		config.PUT("/:key", RequireRole(adminRole...), c.SetParam)
+	}
+}
+
+func (c *ConfigController) GetAllParams(ctx *gin.Context) {
+	params, err := c.configService.GetAllParams()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, params)
+}
+
+func (c *ConfigController) GetParam(ctx *gin.Context) {
+	param, err := c.configService.GetParam(ctx.Param("key"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, param)
+}
+
+func (c *ConfigController) SetParam(ctx *gin.Context) {
+	var request configParamRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	param, err := c.configService.SetParam(ctx.Param("key"), request.Value)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, param)
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EggPriceController struct {
+	eggPriceService *service.EggPriceService
+}
+
+func NewEggPriceController(eggPriceService *service.EggPriceService) *EggPriceController {
+	return &EggPriceController{
+		eggPriceService: eggPriceService,
+	}
+}
+
+type eggPriceRequest struct {
+	Price         float64 `json:"price" binding:"required"`
+	EffectiveFrom string  `json:"effective_from" binding:"required"` // в формате YYYY-MM-DD
+}
+
+func (c *EggPriceController) RegisterRoutes(router *gin.Engine) {
+	prices := router.Group("/api/egg-prices")
+	{
+		prices.GET("", RequireRole(managerRole...), c.GetPriceHistory)
+		prices.POST("", RequireRole(adminRole...), c.SetPrice)
+		prices.DELETE("/:id", RequireRole(adminRole...), c.DeletePrice)
+	}
+}
+
+func (c *EggPriceController) GetPriceHistory(ctx *gin.Context) {
+	prices, err := c.eggPriceService.GetPriceHistory()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, prices)
+}
+
+func (c *EggPriceController) SetPrice(ctx *gin.Context) {
+	var request eggPriceRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	effectiveFrom, err := time.Parse(dateLayout, request.EffectiveFrom)
+	if err != nil {
+		respondBadRequest(ctx, "invalid effective_from")
+		return
+	}
+
+	price := model.EggPrice{
+		Price:         request.Price,
+		EffectiveFrom: effectiveFrom,
+	}
+	if err := c.eggPriceService.SetPrice(&price); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, price)
+}
+
+func (c *EggPriceController) DeletePrice(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	if err := c.eggPriceService.DeletePrice(uint(id)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "egg price deleted successfully"})
+}
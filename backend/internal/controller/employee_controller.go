@@ -5,6 +5,8 @@ import (
 	"strconv"
 
 	"chicken-farm/internal/model"
+	"chicken-farm/internal/passport"
+	"chicken-farm/internal/repository"
 	"chicken-farm/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -23,106 +25,146 @@ func NewEmployeeController(employeeService *service.EmployeeService) *EmployeeCo
 func (c *EmployeeController) RegisterRoutes(router *gin.Engine) {
 	employees := router.Group("/api/employees")
 	{
-		employees.GET("", c.GetAllEmployees)
-		employees.GET("/:id", c.GetEmployeeByID)
-		employees.POST("", c.CreateEmployee)
-		employees.PUT("/:id", c.UpdateEmployee)
-		employees.DELETE("/:id", c.DeleteEmployee)
-		employees.GET("/:id/chicken-count", c.GetEmployeeChickenCount)
-		employees.GET("/chicken-counts", c.GetAllEmployeeChickenCounts)
-		employees.GET("/:id/egg-count", c.GetEmployeeEggCount)
-		employees.GET("/egg-counts", c.GetAllEmployeeEggCounts)
+		employees.GET("", RequireRole(managerRole...), c.GetAllEmployees)
+		employees.GET("/:id", RequireRole(managerRole...), c.GetEmployeeByID)
+		employees.POST("", RequireRole(managerRole...), c.CreateEmployee)
+		employees.PUT("/:id", RequireRole(managerRole...), c.UpdateEmployee)
+		employees.DELETE("/:id", RequireRole(adminRole...), c.DeleteEmployee)
+		employees.GET("/:id/cage-history", RequireRole(managerRole...), c.GetCageHistory)
+		employees.GET("/:id/chicken-count", RequireRole(managerRole...), c.GetEmployeeChickenCount)
+		employees.GET("/chicken-counts", RequireRole(managerRole...), c.GetAllEmployeeChickenCounts)
+		employees.GET("/:id/egg-count", RequireRole(managerRole...), c.GetEmployeeEggCount)
+		employees.GET("/egg-counts", RequireRole(managerRole...), c.GetAllEmployeeEggCounts)
 	}
 }
 
 func (c *EmployeeController) GetAllEmployees(ctx *gin.Context) {
-	employees, err := c.employeeService.GetAllEmployees()
+	list, ok := listQuery(ctx, repository.EmployeeSortColumns)
+	if !ok {
+		return
+	}
+
+	var filter repository.EmployeeFilter
+	if filter.MinSalary, ok = floatQuery(ctx, "min_salary"); !ok {
+		return
+	}
+	if filter.MaxSalary, ok = floatQuery(ctx, "max_salary"); !ok {
+		return
+	}
+
+	employees, total, err := c.employeeService.ListEmployees(filter, list)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
+	setPageHeaders(ctx, list, total)
+	maskPassports(ctx, employees)
 	ctx.JSON(http.StatusOK, employees)
 }
 
 func (c *EmployeeController) GetEmployeeByID(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		respondBadRequest(ctx, "invalid ID")
 		return
 	}
 
 	employee, err := c.employeeService.GetEmployeeByID(uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "employee not found"})
+		respondError(ctx, err)
 		return
 	}
 
+	maskPassport(ctx, employee)
 	ctx.JSON(http.StatusOK, employee)
 }
 
 func (c *EmployeeController) CreateEmployee(ctx *gin.Context) {
 	var employee model.Employee
 	if err := ctx.ShouldBindJSON(&employee); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(ctx, err)
+		return
+	}
+
+	if employee.PassportData == "" {
+		respondFieldErrors(ctx, []FieldError{{Field: "passport_data", Rule: "required", Message: "is required"}})
 		return
 	}
 
 	if err := c.employeeService.CreateEmployee(&employee); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
+	maskPassport(ctx, &employee)
 	ctx.JSON(http.StatusCreated, employee)
 }
 
 func (c *EmployeeController) UpdateEmployee(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		respondBadRequest(ctx, "invalid ID")
 		return
 	}
 
 	var employee model.Employee
 	if err := ctx.ShouldBindJSON(&employee); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(ctx, err)
 		return
 	}
 
 	employee.ID = uint(id)
 	if err := c.employeeService.UpdateEmployee(&employee); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
+	maskPassport(ctx, &employee)
 	ctx.JSON(http.StatusOK, employee)
 }
 
 func (c *EmployeeController) DeleteEmployee(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		respondBadRequest(ctx, "invalid ID")
 		return
 	}
 
 	if err := c.employeeService.DeleteEmployee(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "employee deleted successfully"})
 }
 
+func (c *EmployeeController) GetCageHistory(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	history, err := c.employeeService.GetCageHistory(uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
+
 func (c *EmployeeController) GetEmployeeChickenCount(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		respondBadRequest(ctx, "invalid ID")
 		return
 	}
 
 	count, err := c.employeeService.GetEmployeeChickenCount(uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -132,7 +174,7 @@ func (c *EmployeeController) GetEmployeeChickenCount(ctx *gin.Context) {
 func (c *EmployeeController) GetAllEmployeeChickenCounts(ctx *gin.Context) {
 	counts, err := c.employeeService.GetAllEmployeeChickenCounts()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -142,21 +184,18 @@ func (c *EmployeeController) GetAllEmployeeChickenCounts(ctx *gin.Context) {
 func (c *EmployeeController) GetEmployeeEggCount(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		respondBadRequest(ctx, "invalid ID")
 		return
 	}
 
-	startDate := ctx.Query("start_date")
-	endDate := ctx.Query("end_date")
-
-	if startDate == "" || endDate == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date are required"})
+	startDate, endDate, ok := dateRangeQuery(ctx)
+	if !ok {
 		return
 	}
 
 	count, err := c.employeeService.GetEmployeeEggCount(uint(id), startDate, endDate)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -169,17 +208,14 @@ func (c *EmployeeController) GetEmployeeEggCount(ctx *gin.Context) {
 }
 
 func (c *EmployeeController) GetAllEmployeeEggCounts(ctx *gin.Context) {
-	startDate := ctx.Query("start_date")
-	endDate := ctx.Query("end_date")
-
-	if startDate == "" || endDate == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date are required"})
+	startDate, endDate, ok := dateRangeQuery(ctx)
+	if !ok {
 		return
 	}
 
 	counts, err := c.employeeService.GetAllEmployeeEggCounts(startDate, endDate)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -189,3 +225,17 @@ func (c *EmployeeController) GetAllEmployeeEggCounts(ctx *gin.Context) {
 		"counts":     counts,
 	})
 }
+
+// maskPassport скрывает номер паспорта от пользователей без права can_view_passports
+func maskPassport(ctx *gin.Context, employee *model.Employee) {
+	if user := currentUser(ctx); user != nil && user.CanViewPassports {
+		return
+	}
+	employee.PassportData = passport.Mask(employee.PassportData)
+}
+
+func maskPassports(ctx *gin.Context, employees []model.Employee) {
+	for i := range employees {
+		maskPassport(ctx, &employees[i])
+	}
+}
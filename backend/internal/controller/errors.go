@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError отвечает на ошибку сервиса статусом, соответствующим ее виду.
+// Тело ответа всегда {"error": "...", "code": "..."}, где code - машиночитаемый код ошибки.
+func respondError(ctx *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	code := "internal_error"
+
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		status = http.StatusNotFound
+		code = "not_found"
+	case errors.Is(err, service.ErrConflict):
+		status = http.StatusConflict
+		code = "conflict"
+	case errors.Is(err, service.ErrValidation):
+		status = http.StatusUnprocessableEntity
+		code = "validation_failed"
+	case errors.Is(err, service.ErrUnauthorized):
+		status = http.StatusUnauthorized
+		code = "unauthorized"
+	case errors.Is(err, service.ErrForbidden):
+		status = http.StatusForbidden
+		code = "forbidden"
+	}
+
+	var domainErr *service.Error
+	if errors.As(err, &domainErr) {
+		code = domainErr.Code
+	}
+
+	ctx.JSON(status, gin.H{"error": err.Error(), "code": code})
+}
+
+// respondBadRequest отвечает на запрос, который не удалось разобрать
+func respondBadRequest(ctx *gin.Context, message string) {
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": message, "code": "bad_request"})
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const dateLayout = "2006-01-02"
+
+type FarmRecordController struct {
+	farmRecordService *service.FarmRecordService
+}
+
+func NewFarmRecordController(farmRecordService *service.FarmRecordService) *FarmRecordController {
+	return &FarmRecordController{
+		farmRecordService: farmRecordService,
+	}
+}
+
+type farmRecordRequest struct {
+	Date      string `json:"date" binding:"required"` // в формате YYYY-MM-DD
+	CageID    uint   `json:"cage_id" binding:"required"`
+	ChickenID uint   `json:"chicken_id" binding:"required"`
+	HasEgg    bool   `json:"has_egg"`
+}
+
+func (r *farmRecordRequest) toModel() (*model.Farm, error) {
+	date, err := time.Parse(dateLayout, r.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Farm{
+		Date:      date,
+		CageID:    r.CageID,
+		ChickenID: r.ChickenID,
+		HasEgg:    r.HasEgg,
+	}, nil
+}
+
+func (c *FarmRecordController) RegisterRoutes(router *gin.Engine) {
+	records := router.Group("/api/farm-records")
+	{
+		records.GET("", RequireRole(anyRole...), c.GetRecords)
+		records.GET("/:id", RequireRole(anyRole...), c.GetRecordByID)
+		records.POST("", RequireRole(anyRole...), c.CreateRecord)
+		records.PUT("/:id", RequireRole(anyRole...), c.UpdateRecord)
+		records.DELETE("/:id", RequireRole(anyRole...), c.DeleteRecord)
+	}
+}
+
+func (c *FarmRecordController) GetRecords(ctx *gin.Context) {
+	var filter repository.FarmRecordFilter
+
+	if startDateStr := ctx.Query("start_date"); startDateStr != "" {
+		startDate, err := time.Parse(dateLayout, startDateStr)
+		if err != nil {
+			respondBadRequest(ctx, "invalid start_date")
+			return
+		}
+		filter.StartDate = &startDate
+	}
+
+	if endDateStr := ctx.Query("end_date"); endDateStr != "" {
+		endDate, err := time.Parse(dateLayout, endDateStr)
+		if err != nil {
+			respondBadRequest(ctx, "invalid end_date")
+			return
+		}
+		filter.EndDate = &endDate
+	}
+
+	if cageIDStr := ctx.Query("cage_id"); cageIDStr != "" {
+		cageID, err := strconv.Atoi(cageIDStr)
+		if err != nil {
+			respondBadRequest(ctx, "invalid cage_id")
+			return
+		}
+		filter.CageID = uint(cageID)
+	}
+
+	if chickenIDStr := ctx.Query("chicken_id"); chickenIDStr != "" {
+		chickenID, err := strconv.Atoi(chickenIDStr)
+		if err != nil {
+			respondBadRequest(ctx, "invalid chicken_id")
+			return
+		}
+		filter.ChickenID = uint(chickenID)
+	}
+
+	records, err := c.farmRecordService.GetRecords(filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, records)
+}
+
+func (c *FarmRecordController) GetRecordByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	record, err := c.farmRecordService.GetRecordByID(uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
+
+func (c *FarmRecordController) CreateRecord(ctx *gin.Context) {
+	var request farmRecordRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	record, err := request.toModel()
+	if err != nil {
+		respondBadRequest(ctx, "invalid date")
+		return
+	}
+
+	if err := c.farmRecordService.CreateRecord(currentUser(ctx), record); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, record)
+}
+
+func (c *FarmRecordController) UpdateRecord(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	var request farmRecordRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	record, err := request.toModel()
+	if err != nil {
+		respondBadRequest(ctx, "invalid date")
+		return
+	}
+
+	record.ID = uint(id)
+	if err := c.farmRecordService.UpdateRecord(currentUser(ctx), record); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
+
+func (c *FarmRecordController) DeleteRecord(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	if err := c.farmRecordService.DeleteRecord(currentUser(ctx), uint(id)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "farm record deleted successfully"})
+}
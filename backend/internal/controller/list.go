@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"chicken-farm/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+)
+
+// listQuery читает page, per_page и sort ("-weight,breed": минус - по убыванию).
+// Без page и per_page возвращается весь список, как до появления страниц.
+// При ошибке отвечает 400 и возвращает ok = false.
+func listQuery(ctx *gin.Context, columns map[string]string) (repository.ListQuery, bool) {
+	list := repository.ListQuery{Page: 1}
+	if ctx.Query("page") != "" || ctx.Query("per_page") != "" {
+		list.PerPage = defaultPerPage
+	}
+
+	if value := ctx.Query("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page <= 0 {
+			respondBadRequest(ctx, "invalid page")
+			return list, false
+		}
+		list.Page = page
+	}
+
+	if value := ctx.Query("per_page"); value != "" {
+		perPage, err := strconv.Atoi(value)
+		if err != nil || perPage <= 0 || perPage > maxPerPage {
+			respondBadRequest(ctx, fmt.Sprintf("per_page must be between 1 and %d", maxPerPage))
+			return list, false
+		}
+		list.PerPage = perPage
+	}
+
+	if value := ctx.Query("sort"); value != "" {
+		for _, part := range strings.Split(value, ",") {
+			field := repository.SortField{Field: strings.TrimSpace(part)}
+			if strings.HasPrefix(field.Field, "-") {
+				field.Field, field.Desc = field.Field[1:], true
+			}
+			if _, ok := columns[field.Field]; !ok {
+				respondBadRequest(ctx, "invalid sort field: "+field.Field)
+				return list, false
+			}
+			list.Sort = append(list.Sort, field)
+		}
+	}
+
+	return list, true
+}
+
+// floatQuery читает необязательный числовой параметр; при ошибке отвечает 400 и возвращает ok = false
+func floatQuery(ctx *gin.Context, name string) (*float64, bool) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, true
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) {
+		respondBadRequest(ctx, "invalid "+name)
+		return nil, false
+	}
+	return &number, true
+}
+
+// setPageHeaders сообщает общее число строк в X-Total-Count и ссылки на соседние страницы в Link
+func setPageHeaders(ctx *gin.Context, list repository.ListQuery, total int64) {
+	ctx.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	if list.PerPage == 0 {
+		return
+	}
+
+	var links []string
+	if int64(list.Page*list.PerPage) < total {
+		links = append(links, pageLink(ctx, list, list.Page+1, "next"))
+	}
+	if list.Page > 1 {
+		links = append(links, pageLink(ctx, list, list.Page-1, "prev"))
+	}
+	if len(links) > 0 {
+		ctx.Header("Link", strings.Join(links, ", "))
+	}
+}
+
+func pageLink(ctx *gin.Context, list repository.ListQuery, page int, rel string) string {
+	query := ctx.Request.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(list.PerPage))
+	return fmt.Sprintf(`<%s?%s>; rel="%s"`, ctx.Request.URL.Path, query.Encode(), rel)
+}
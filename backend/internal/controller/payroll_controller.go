@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PayrollController struct {
+	payrollService *service.PayrollService
+}
+
+func NewPayrollController(payrollService *service.PayrollService) *PayrollController {
+	return &PayrollController{
+		payrollService: payrollService,
+	}
+}
+
+type absenceRequest struct {
+	EmployeeID uint   `json:"employee_id" binding:"required,gt=0"`
+	Date       string `json:"date" binding:"required"` // в формате YYYY-MM-DD
+	Reason     string `json:"reason" binding:"max=255"`
+}
+
+func (c *PayrollController) RegisterRoutes(router *gin.Engine) {
+	payroll := router.Group("/api/payroll")
+	{
+		payroll.GET("/absences", RequireRole(managerRole...), c.GetAbsences)
+		payroll.POST("/absences", RequireRole(managerRole...), c.CreateAbsence)
+		payroll.DELETE("/absences/:id", RequireRole(managerRole...), c.DeleteAbsence)
+		payroll.GET("/:period", RequireRole(managerRole...), c.GetPayroll)
+		payroll.POST("/:period", RequireRole(managerRole...), c.CalculatePayroll)
+		payroll.POST("/:period/finalize", RequireRole(adminRole...), c.FinalizePayroll)
+	}
+}
+
+func (c *PayrollController) GetPayroll(ctx *gin.Context) {
+	payroll, err := c.payrollService.GetPayroll(ctx.Param("period"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, payroll)
+}
+
+// CalculatePayroll пересчитывает черновики листков за месяц
+func (c *PayrollController) CalculatePayroll(ctx *gin.Context) {
+	payroll, err := c.payrollService.Calculate(ctx.Param("period"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, payroll)
+}
+
+func (c *PayrollController) FinalizePayroll(ctx *gin.Context) {
+	payroll, err := c.payrollService.Finalize(ctx.Param("period"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, payroll)
+}
+
+func (c *PayrollController) GetAbsences(ctx *gin.Context) {
+	period := ctx.Query("period")
+	if period == "" {
+		respondBadRequest(ctx, "period is required")
+		return
+	}
+
+	absences, err := c.payrollService.GetAbsences(period)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, absences)
+}
+
+func (c *PayrollController) CreateAbsence(ctx *gin.Context) {
+	var request absenceRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	date, err := time.Parse(dateLayout, request.Date)
+	if err != nil {
+		respondBadRequest(ctx, "invalid date")
+		return
+	}
+
+	absence := model.Absence{
+		EmployeeID: request.EmployeeID,
+		Date:       date,
+		Reason:     request.Reason,
+	}
+	if err := c.payrollService.CreateAbsence(&absence); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, absence)
+}
+
+func (c *PayrollController) DeleteAbsence(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	if err := c.payrollService.DeleteAbsence(uint(id)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "absence deleted successfully"})
+}
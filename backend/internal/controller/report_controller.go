@@ -2,6 +2,9 @@ package controller
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"chicken-farm/internal/service"
 
@@ -21,51 +24,51 @@ func NewReportController(reportService *service.ReportService) *ReportController
 func (c *ReportController) RegisterRoutes(router *gin.Engine) {
 	reports := router.Group("/api/reports")
 	{
-		reports.GET("/egg-stats", c.GetTotalEggStats)
-		reports.GET("/employee-egg-stats", c.GetEmployeeEggStats)
-		reports.GET("/low-productivity-chickens", c.GetLowProductivityChickens)
-		reports.GET("/most-productive-chicken", c.GetMostProductiveChickenStats)
-		reports.GET("/employee-chicken-counts", c.GetEmployeeChickenCountStats)
+		reports.GET("/egg-stats", RequireRole(managerRole...), c.GetTotalEggStats)
+		reports.GET("/employee-egg-stats", RequireRole(managerRole...), c.GetEmployeeEggStats)
+		reports.GET("/low-productivity-chickens", RequireRole(managerRole...), c.GetLowProductivityChickens)
+		reports.GET("/most-productive-chicken", RequireRole(managerRole...), c.GetMostProductiveChickenStats)
+		reports.GET("/employee-chicken-counts", RequireRole(managerRole...), c.GetEmployeeChickenCountStats)
+		reports.GET("/unattended-cages", RequireRole(managerRole...), c.GetUnattendedCages)
+		reports.GET("/attendance", RequireRole(managerRole...), c.GetAttendance)
+		reports.GET("/weight-loss-alerts", RequireRole(managerRole...), c.GetWeightLossAlerts)
+		reports.GET("/productivity-matrix", RequireRole(managerRole...), c.GetProductivityMatrix)
 	}
 }
 
 func (c *ReportController) GetTotalEggStats(ctx *gin.Context) {
-	startDate := ctx.Query("start_date")
-	endDate := ctx.Query("end_date")
-
-	if startDate == "" || endDate == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date are required"})
+	startDate, endDate, ok := dateRangeQuery(ctx)
+	if !ok {
 		return
 	}
 
 	stats, err := c.reportService.GetTotalEggStats(startDate, endDate)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	response := gin.H{
-		"start_date": startDate,
-		"end_date":   endDate,
-		"total_eggs": stats.TotalEggs,
-		"total_cost": stats.TotalCost,
+		"start_date":    startDate,
+		"end_date":      endDate,
+		"total_eggs":    stats.TotalEggs,
+		"total_cost":    stats.TotalCost,
+		"egg_price":     stats.EggPrice,
+		"price_periods": stats.PricePeriods,
 	}
 
 	ctx.JSON(http.StatusOK, response)
 }
 
 func (c *ReportController) GetEmployeeEggStats(ctx *gin.Context) {
-	startDate := ctx.Query("start_date")
-	endDate := ctx.Query("end_date")
-
-	if startDate == "" || endDate == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date are required"})
+	startDate, endDate, ok := dateRangeQuery(ctx)
+	if !ok {
 		return
 	}
 
 	stats, err := c.reportService.GetEmployeeEggStats(startDate, endDate)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -81,7 +84,7 @@ func (c *ReportController) GetEmployeeEggStats(ctx *gin.Context) {
 func (c *ReportController) GetLowProductivityChickens(ctx *gin.Context) {
 	chickens, err := c.reportService.GetLowProductivityChickens()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -91,7 +94,7 @@ func (c *ReportController) GetLowProductivityChickens(ctx *gin.Context) {
 func (c *ReportController) GetMostProductiveChickenStats(ctx *gin.Context) {
 	stats, err := c.reportService.GetMostProductiveChickenStats()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -101,9 +104,130 @@ func (c *ReportController) GetMostProductiveChickenStats(ctx *gin.Context) {
 func (c *ReportController) GetEmployeeChickenCountStats(ctx *gin.Context) {
 	stats, err := c.reportService.GetEmployeeChickenCountStats()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, stats)
 }
+
+func (c *ReportController) GetUnattendedCages(ctx *gin.Context) {
+	cages, err := c.reportService.GetUnattendedCages()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cages)
+}
+
+func (c *ReportController) GetWeightLossAlerts(ctx *gin.Context) {
+	startDate, endDate, ok := dateRangeQuery(ctx)
+	if !ok {
+		return
+	}
+
+	alerts, err := c.reportService.GetWeightLossAlerts(startDate, endDate)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"start_date": startDate,
+		"end_date":   endDate,
+		"alerts":     alerts,
+	})
+}
+
+// GetAttendance возвращает посещаемость за месяц, заданный параметром period=YYYY-MM
+func (c *ReportController) GetAttendance(ctx *gin.Context) {
+	period := ctx.Query("period")
+	if period == "" {
+		respondBadRequest(ctx, "period is required")
+		return
+	}
+
+	attendance, err := c.reportService.GetAttendance(period)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"period":     period,
+		"attendance": attendance,
+	})
+}
+
+// GetProductivityMatrix строит матрицу яйценоскости по полосам веса и возраста. Полосы задаются
+// границами (weight_edges=2,2.5,3) или шириной (weight_step=0.5); так же для возраста в месяцах.
+func (c *ReportController) GetProductivityMatrix(ctx *gin.Context) {
+	weightSpec, ok := bandSpecQuery(ctx, "weight", 0.5)
+	if !ok {
+		return
+	}
+	ageSpec, ok := bandSpecQuery(ctx, "age", 6)
+	if !ok {
+		return
+	}
+
+	matrix, err := c.reportService.GetProductivityMatrix(weightSpec, ageSpec)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, matrix)
+}
+
+// bandSpecQuery читает <axis>_edges или <axis>_step; при ошибке отвечает 400 и возвращает ok = false
+func bandSpecQuery(ctx *gin.Context, axis string, defaultStep float64) (service.BandSpec, bool) {
+	spec := service.BandSpec{Step: defaultStep}
+
+	if value := ctx.Query(axis + "_edges"); value != "" {
+		for _, part := range strings.Split(value, ",") {
+			edge, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				respondBadRequest(ctx, "invalid "+axis+"_edges")
+				return spec, false
+			}
+			spec.Edges = append(spec.Edges, edge)
+		}
+		return spec, true
+	}
+
+	if value := ctx.Query(axis + "_step"); value != "" {
+		step, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			respondBadRequest(ctx, "invalid "+axis+"_step")
+			return spec, false
+		}
+		spec.Step = step
+	}
+
+	return spec, true
+}
+
+// dateRangeQuery читает обязательные start_date и end_date в формате YYYY-MM-DD;
+// при ошибке отвечает 400 и возвращает ok = false
+func dateRangeQuery(ctx *gin.Context) (startDate, endDate string, ok bool) {
+	startDate = ctx.Query("start_date")
+	endDate = ctx.Query("end_date")
+
+	if startDate == "" || endDate == "" {
+		respondBadRequest(ctx, "start_date and end_date are required")
+		return "", "", false
+	}
+
+	if _, err := time.Parse(dateLayout, startDate); err != nil {
+		respondBadRequest(ctx, "invalid start_date")
+		return "", "", false
+	}
+	if _, err := time.Parse(dateLayout, endDate); err != nil {
+		respondBadRequest(ctx, "invalid end_date")
+		return "", "", false
+	}
+
+	return startDate, endDate, true
+}
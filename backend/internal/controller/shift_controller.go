@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShiftController struct {
+	shiftService *service.ShiftService
+}
+
+func NewShiftController(shiftService *service.ShiftService) *ShiftController {
+	return &ShiftController{
+		shiftService: shiftService,
+	}
+}
+
+type shiftRequest struct {
+	EmployeeID uint      `json:"employee_id" binding:"required,gt=0"`
+	StartsAt   time.Time `json:"starts_at" binding:"required"` // RFC 3339
+	EndsAt     time.Time `json:"ends_at" binding:"required"`
+}
+
+// clockRequest - тело отметки прихода или ухода; работник может прислать пустое тело
+type clockRequest struct {
+	EmployeeID *uint `json:"employee_id" binding:"omitempty,gt=0"`
+}
+
+func (c *ShiftController) RegisterRoutes(router *gin.Engine) {
+	shifts := router.Group("/api/shifts")
+	{
+		shifts.GET("", RequireRole(anyRole...), c.GetShifts)
+		shifts.POST("", RequireRole(managerRole...), c.CreateShift)
+		shifts.DELETE("/:id", RequireRole(managerRole...), c.DeleteShift)
+		shifts.POST("/clock-in", RequireRole(anyRole...), c.ClockIn)
+		shifts.POST("/clock-out", RequireRole(anyRole...), c.ClockOut)
+		shifts.GET("/time-entries", RequireRole(managerRole...), c.GetTimeEntries)
+	}
+}
+
+func (c *ShiftController) GetShifts(ctx *gin.Context) {
+	start, end, ok := dayRangeQuery(ctx)
+	if !ok {
+		return
+	}
+
+	filter := repository.ShiftFilter{Start: start, End: end}
+	if value := ctx.Query("employee_id"); value != "" {
+		employeeID, err := strconv.Atoi(value)
+		if err != nil || employeeID <= 0 {
+			respondBadRequest(ctx, "invalid employee_id")
+			return
+		}
+		filter.EmployeeID = uint(employeeID)
+	}
+
+	shifts, err := c.shiftService.GetShifts(currentUser(ctx), filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, shifts)
+}
+
+func (c *ShiftController) CreateShift(ctx *gin.Context) {
+	var request shiftRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	shift := model.Shift{
+		EmployeeID: request.EmployeeID,
+		StartsAt:   request.StartsAt,
+		EndsAt:     request.EndsAt,
+	}
+	if err := c.shiftService.CreateShift(&shift); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, shift)
+}
+
+func (c *ShiftController) DeleteShift(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	if err := c.shiftService.DeleteShift(uint(id)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "shift deleted successfully"})
+}
+
+func (c *ShiftController) ClockIn(ctx *gin.Context) {
+	request, ok := bindClockRequest(ctx)
+	if !ok {
+		return
+	}
+
+	entry, err := c.shiftService.ClockIn(currentUser(ctx), request.EmployeeID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, entry)
+}
+
+func (c *ShiftController) ClockOut(ctx *gin.Context) {
+	request, ok := bindClockRequest(ctx)
+	if !ok {
+		return
+	}
+
+	entry, err := c.shiftService.ClockOut(currentUser(ctx), request.EmployeeID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entry)
+}
+
+func (c *ShiftController) GetTimeEntries(ctx *gin.Context) {
+	start, end, ok := dayRangeQuery(ctx)
+	if !ok {
+		return
+	}
+
+	entries, err := c.shiftService.GetTimeEntries(start, end)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
+
+func bindClockRequest(ctx *gin.Context) (clockRequest, bool) {
+	var request clockRequest
+	if ctx.Request.ContentLength == 0 {
+		return request, true
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(ctx, err)
+		return request, false
+	}
+	return request, true
+}
+
+// dayRangeQuery переводит start_date и end_date в полуинтервал [start_date, end_date + 1 день)
+func dayRangeQuery(ctx *gin.Context) (time.Time, time.Time, bool) {
+	startDate, endDate, ok := dateRangeQuery(ctx)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	start, _ := time.Parse(dateLayout, startDate)
+	end, _ := time.Parse(dateLayout, endDate)
+	return start, end.AddDate(0, 0, 1), true
+}
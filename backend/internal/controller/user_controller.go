@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserController struct {
+	userService *service.UserService
+}
+
+func NewUserController(userService *service.UserService) *UserController {
+	return &UserController{
+		userService: userService,
+	}
+}
+
+type userRequest struct {
+	Username   string     `json:"username" binding:"required,max=100"`
+	Password   string     `json:"password" binding:"omitempty,min=8,max=72"` // обязателен при создании
+	Role       model.Role `json:"role" binding:"required,oneof=admin manager worker"`
+	EmployeeID *uint      `json:"employee_id" binding:"omitempty,gt=0"`
+
+	CanViewPassports bool `json:"can_view_passports"` // видеть номера паспортов целиком
+}
+
+func (r *userRequest) toModel() *model.User {
+	return &model.User{
+		Username:         r.Username,
+		Role:             r.Role,
+		EmployeeID:       r.EmployeeID,
+		CanViewPassports: r.CanViewPassports,
+	}
+}
+
+func (c *UserController) RegisterRoutes(router *gin.Engine) {
+	users := router.Group("/api/users")
+	{
+		users.GET("", RequireRole(adminRole...), c.GetAllUsers)
+		users.GET("/:id", RequireRole(adminRole...), c.GetUserByID)
+		users.POST("", RequireRole(adminRole...), c.CreateUser)
+		users.PUT("/:id", RequireRole(adminRole...), c.UpdateUser)
+		users.DELETE("/:id", RequireRole(adminRole...), c.DeleteUser)
+	}
+}
+
+func (c *UserController) GetAllUsers(ctx *gin.Context) {
+	users, err := c.userService.GetAllUsers()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, users)
+}
+
+func (c *UserController) GetUserByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	user, err := c.userService.GetUserByID(uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+func (c *UserController) CreateUser(ctx *gin.Context) {
+	var request userRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	if request.Password == "" {
+		respondFieldErrors(ctx, []FieldError{{Field: "password", Rule: "required", Message: "is required"}})
+		return
+	}
+
+	user := request.toModel()
+	if err := c.userService.CreateUser(user, request.Password); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, user)
+}
+
+func (c *UserController) UpdateUser(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	var request userRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	user := request.toModel()
+	user.ID = uint(id)
+	if err := c.userService.UpdateUser(user, request.Password); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+func (c *UserController) DeleteUser(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid ID")
+		return
+	}
+
+	if err := c.userService.DeleteUser(uint(id)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "user deleted successfully"})
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var passportPattern = regexp.MustCompile(`^\d{4} \d{6}$`)
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// в ошибках поля называются так же, как в JSON
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("passport", func(fl validator.FieldLevel) bool {
+		return passportPattern.MatchString(fl.Field().String())
+	})
+}
+
+// FieldError - ошибка в одном поле запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// respondBindError отвечает на ошибку ShouldBindJSON: нарушения правил валидации возвращаются
+// списком по полям со статусом 422, синтаксические ошибки - со статусом 400
+func respondBindError(ctx *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		respondBadRequest(ctx, err.Error())
+		return
+	}
+
+	fields := make([]FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		fields = append(fields, FieldError{
+			Field:   fieldPath(fieldErr),
+			Rule:    fieldErr.Tag(),
+			Message: fieldErrorMessage(fieldErr),
+		})
+	}
+
+	respondFieldErrors(ctx, fields)
+}
+
+// respondFieldErrors отвечает 422 со списком ошибок по полям
+func respondFieldErrors(ctx *gin.Context, fields []FieldError) {
+	ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":  "validation failed",
+		"code":   "validation_failed",
+		"fields": fields,
+	})
+}
+
+// fieldPath возвращает путь к полю без имени корневой структуры, например "cages[0]"
+func fieldPath(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldErrorMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fieldErr.Param())
+	case "gte", "min":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %s", fieldErr.Param())
+	case "lte", "max":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fieldErr.Param())
+	case "passport":
+		return "must be in the format \"1234 567890\""
+	default:
+		return "is invalid"
+	}
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"chicken-farm/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WorkloadController struct {
+	workloadService *service.WorkloadService
+}
+
+func NewWorkloadController(workloadService *service.WorkloadService) *WorkloadController {
+	return &WorkloadController{
+		workloadService: workloadService,
+	}
+}
+
+func (c *WorkloadController) RegisterRoutes(router *gin.Engine) {
+	workload := router.Group("/api/workload")
+	{
+		workload.POST("/balance", RequireRole(managerRole...), c.Balance)
+	}
+}
+
+// Balance перераспределяет клетки; с ?dry_run=true только возвращает предлагаемые изменения
+func (c *WorkloadController) Balance(ctx *gin.Context) {
+	dryRun, err := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		respondBadRequest(ctx, "invalid dry_run")
+		return
+	}
+
+	plan, err := c.workloadService.Balance(dryRun)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, plan)
+}
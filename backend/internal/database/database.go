@@ -0,0 +1,39 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"chicken-farm/internal/config"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Open подключается к базе данных драйвером, выбранным в конфигурации
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case config.DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(cfg.Path))
+	case config.DriverPostgres:
+		dialector = postgres.Open(cfg.DSN)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+
+	// нарушения уникальности приходят как gorm.ErrDuplicatedKey независимо от драйвера
+	return gorm.Open(dialector, &gorm.Config{TranslateError: true})
+}
+
+// sqliteDSN добавляет к пути параметры подключения. Транзакции сразу берут блокировку записи,
+// поэтому проверка и запись в одной транзакции не перемежаются с другими записями;
+// конкурирующая транзакция ждет блокировку до пяти секунд.
+func sqliteDSN(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + "_txlock=immediate&_busy_timeout=5000"
+}
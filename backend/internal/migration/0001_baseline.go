@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// baseline - схема, которую раньше создавал AutoMigrate при запуске сервера.
+// AutoMigrate не трогает уже существующие таблицы и индексы, поэтому на базе, созданной
+// старой версией приложения, миграция только дописывает недостающее и фиксирует версию 1.
+var baseline = Migration{
+	Version: 1,
+	Name:    "baseline",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(baselineTables()...)
+	},
+	Down: func(tx *gorm.DB) error {
+		tables := baselineTables()
+		for i := len(tables) - 1; i >= 0; i-- {
+			if err := tx.Migrator().DropTable(tables[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func baselineTables() []interface{} {
+	return []interface{}{
+		&baselineChicken{},
+		&baselineEmployee{},
+		&baselineEmployeeCage{},
+		&baselineFarm{},
+		&baselineCage{},
+		&baselineConfigParam{},
+		&baselineEggPrice{},
+	}
+}
+
+type baselineChicken struct {
+	ID          uint    `gorm:"primaryKey"`
+	CageID      uint    `gorm:"not null"`
+	Weight      float64 `gorm:"not null"`
+	Age         int     `gorm:"not null"`
+	EggPerMonth int     `gorm:"not null"`
+	Breed       string  `gorm:"not null"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (baselineChicken) TableName() string {
+	return "chickens"
+}
+
+type baselineEmployee struct {
+	ID           uint    `gorm:"primaryKey"`
+	FullName     string  `gorm:"not null"`
+	PassportData string  `gorm:"not null;uniqueIndex:idx_employees_passport_data"`
+	Salary       float64 `gorm:"not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (baselineEmployee) TableName() string {
+	return "employees"
+}
+
+type baselineEmployeeCage struct {
+	ID         uint `gorm:"primaryKey"`
+	EmployeeID uint `gorm:"not null"`
+	CageID     uint `gorm:"not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (baselineEmployeeCage) TableName() string {
+	return "employee_cages"
+}
+
+type baselineFarm struct {
+	ID        uint      `gorm:"primaryKey"`
+	Date      time.Time `gorm:"not null"`
+	CageID    uint      `gorm:"not null"`
+	ChickenID uint      `gorm:"not null"`
+	HasEgg    bool      `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineFarm) TableName() string {
+	return "farm_records"
+}
+
+type baselineCage struct {
+	ID        uint `gorm:"primaryKey"`
+	Number    int  `gorm:"not null;uniqueIndex:idx_cages_number"`
+	Capacity  int  `gorm:"not null;default:1"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineCage) TableName() string {
+	return "cages"
+}
+
+type baselineConfigParam struct {
+	ID        uint   `gorm:"primaryKey"`
+	Key       string `gorm:"not null;uniqueIndex:idx_config_params_key"`
+	Value     string `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineConfigParam) TableName() string {
+	return "config_params"
+}
+
+type baselineEggPrice struct {
+	ID            uint      `gorm:"primaryKey"`
+	Price         float64   `gorm:"not null"`
+	EffectiveFrom time.Time `gorm:"not null;uniqueIndex:idx_egg_prices_effective_from"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (baselineEggPrice) TableName() string {
+	return "egg_prices"
+}
@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// users добавляет учетные записи и сессии
+var users = Migration{
+	Version: 2,
+	Name:    "users",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&usersUser{}, &usersSession{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&usersSession{}, &usersUser{})
+	},
+}
+
+type usersUser struct {
+	ID           uint   `gorm:"primaryKey"`
+	Username     string `gorm:"not null;uniqueIndex:idx_users_username"`
+	PasswordHash string `gorm:"not null"`
+	Role         string `gorm:"not null"`
+	EmployeeID   *uint
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (usersUser) TableName() string {
+	return "users"
+}
+
+type usersSession struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index:idx_sessions_user_id"`
+	TokenHash string    `gorm:"not null;uniqueIndex:idx_sessions_token_hash"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+func (usersSession) TableName() string {
+	return "sessions"
+}
@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// passportEncryption готовит схему к шифрованию паспортов: уникальность теперь проверяется
+// по HMAC номера, а не по самому (зашифрованному) значению. Старые строки получают хэш
+// командой encrypt-passports, до этого он равен NULL и в уникальном индексе не участвует.
+var passportEncryption = Migration{
+	Version: 3,
+	Name:    "passport_encryption",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if err := migrator.AddColumn(&passportEncryptionEmployee{}, "PassportHash"); err != nil {
+			return err
+		}
+		if err := migrator.CreateIndex(&passportEncryptionEmployee{}, "idx_employees_passport_hash"); err != nil {
+			return err
+		}
+		if err := migrator.DropIndex(&baselineEmployee{}, "idx_employees_passport_data"); err != nil {
+			return err
+		}
+
+		return migrator.AddColumn(&passportEncryptionUser{}, "CanViewPassports")
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if err := dropColumn(tx, &passportEncryptionUser{}, "CanViewPassports"); err != nil {
+			return err
+		}
+		if err := migrator.DropIndex(&passportEncryptionEmployee{}, "idx_employees_passport_hash"); err != nil {
+			return err
+		}
+		if err := dropColumn(tx, &passportEncryptionEmployee{}, "PassportHash"); err != nil {
+			return err
+		}
+
+		return migrator.CreateIndex(&baselineEmployee{}, "idx_employees_passport_data")
+	},
+}
+
+type passportEncryptionEmployee struct {
+	PassportHash *string `gorm:"uniqueIndex:idx_employees_passport_hash"`
+}
+
+func (passportEncryptionEmployee) TableName() string {
+	return "employees"
+}
+
+type passportEncryptionUser struct {
+	CanViewPassports bool `gorm:"not null;default:false"`
+}
+
+func (passportEncryptionUser) TableName() string {
+	return "users"
+}
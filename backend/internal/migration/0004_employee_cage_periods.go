@@ -0,0 +1,92 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// employeeCagePeriods превращает закрепления клеток в историю: у каждой строки появляется
+// период действия. Раньше строки пересоздавались при каждом изменении сотрудника, поэтому
+// началом периода существующих закреплений считается день их создания.
+var employeeCagePeriods = Migration{
+	Version: 4,
+	Name:    "employee_cage_periods",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if err := migrator.AddColumn(&employeeCagePeriodsNullable{}, "ValidFrom"); err != nil {
+			return err
+		}
+		if err := migrator.AddColumn(&employeeCagePeriodsNullable{}, "ValidTo"); err != nil {
+			return err
+		}
+
+		var rows []employeeCagePeriodsNullable
+		if err := tx.Select("id", "created_at").Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			year, month, day := row.CreatedAt.UTC().Date()
+			validFrom := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+			err := tx.Model(&employeeCagePeriodsNullable{}).Where("id = ?", row.ID).
+				Update("valid_from", validFrom).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := migrator.AlterColumn(&employeeCagePeriodsEmployeeCage{}, "ValidFrom"); err != nil {
+			return err
+		}
+		if err := migrator.CreateIndex(&employeeCagePeriodsEmployeeCage{}, "idx_employee_cages_employee_id"); err != nil {
+			return err
+		}
+		return migrator.CreateIndex(&employeeCagePeriodsEmployeeCage{}, "idx_employee_cages_cage_id")
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		// без истории остаются только действующие закрепления
+		if err := tx.Where("valid_to IS NOT NULL").Delete(&employeeCagePeriodsEmployeeCage{}).Error; err != nil {
+			return err
+		}
+
+		if err := migrator.DropIndex(&employeeCagePeriodsEmployeeCage{}, "idx_employee_cages_cage_id"); err != nil {
+			return err
+		}
+		if err := migrator.DropIndex(&employeeCagePeriodsEmployeeCage{}, "idx_employee_cages_employee_id"); err != nil {
+			return err
+		}
+		if err := dropColumn(tx, &employeeCagePeriodsEmployeeCage{}, "ValidTo"); err != nil {
+			return err
+		}
+		return dropColumn(tx, &employeeCagePeriodsEmployeeCage{}, "ValidFrom")
+	},
+}
+
+type employeeCagePeriodsEmployeeCage struct {
+	ID         uint      `gorm:"primaryKey"`
+	EmployeeID uint      `gorm:"not null;index:idx_employee_cages_employee_id"`
+	CageID     uint      `gorm:"not null;index:idx_employee_cages_cage_id"`
+	ValidFrom  time.Time `gorm:"not null"`
+	ValidTo    *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (employeeCagePeriodsEmployeeCage) TableName() string {
+	return "employee_cages"
+}
+
+// employeeCagePeriodsNullable - та же таблица до заполнения valid_from у старых строк
+type employeeCagePeriodsNullable struct {
+	ID        uint `gorm:"primaryKey"`
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+	CreatedAt time.Time
+}
+
+func (employeeCagePeriodsNullable) TableName() string {
+	return "employee_cages"
+}
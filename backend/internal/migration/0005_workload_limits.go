@@ -0,0 +1,41 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// workloadLimits добавляет данные для распределения клеток: ряд клетки и предельную нагрузку сотрудника
+var workloadLimits = Migration{
+	Version: 5,
+	Name:    "workload_limits",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if err := migrator.AddColumn(&workloadLimitsCage{}, "Row"); err != nil {
+			return err
+		}
+		return migrator.AddColumn(&workloadLimitsEmployee{}, "MaxChickens")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropColumn(tx, &workloadLimitsEmployee{}, "MaxChickens"); err != nil {
+			return err
+		}
+		return dropColumn(tx, &workloadLimitsCage{}, "Row")
+	},
+}
+
+type workloadLimitsCage struct {
+	Row string `gorm:"not null;default:''"`
+}
+
+func (workloadLimitsCage) TableName() string {
+	return "cages"
+}
+
+type workloadLimitsEmployee struct {
+	MaxChickens int `gorm:"not null;default:0"`
+}
+
+func (workloadLimitsEmployee) TableName() string {
+	return "employees"
+}
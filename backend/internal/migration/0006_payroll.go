@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// payroll добавляет расчетные листки и учет пропущенных дней
+var payroll = Migration{
+	Version: 6,
+	Name:    "payroll",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&payrollPayslip{}, &payrollPayslipLine{}, &payrollAbsence{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&payrollAbsence{}, &payrollPayslipLine{}, &payrollPayslip{})
+	},
+}
+
+type payrollPayslip struct {
+	ID           uint    `gorm:"primaryKey"`
+	Period       string  `gorm:"not null;uniqueIndex:idx_payslips_period_employee"`
+	EmployeeID   uint    `gorm:"not null;uniqueIndex:idx_payslips_period_employee"`
+	EmployeeName string  `gorm:"not null"`
+	EggCount     int     `gorm:"not null"`
+	AbsenceDays  int     `gorm:"not null"`
+	BaseSalary   float64 `gorm:"not null"`
+	Bonus        float64 `gorm:"not null"`
+	Deductions   float64 `gorm:"not null"`
+	Total        float64 `gorm:"not null"`
+	Status       string  `gorm:"not null"`
+	FinalizedAt  *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (payrollPayslip) TableName() string {
+	return "payslips"
+}
+
+type payrollPayslipLine struct {
+	ID          uint    `gorm:"primaryKey"`
+	PayslipID   uint    `gorm:"not null;index:idx_payslip_lines_payslip_id"`
+	Kind        string  `gorm:"not null"`
+	Description string  `gorm:"not null"`
+	Quantity    float64 `gorm:"not null"`
+	Rate        float64 `gorm:"not null"`
+	Amount      float64 `gorm:"not null"`
+}
+
+func (payrollPayslipLine) TableName() string {
+	return "payslip_lines"
+}
+
+type payrollAbsence struct {
+	ID         uint      `gorm:"primaryKey"`
+	EmployeeID uint      `gorm:"not null;uniqueIndex:idx_absences_employee_date"`
+	Date       time.Time `gorm:"not null;uniqueIndex:idx_absences_employee_date"`
+	Reason     string    `gorm:"not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (payrollAbsence) TableName() string {
+	return "absences"
+}
@@ -0,0 +1,46 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// shifts добавляет график смен и отметки прихода и ухода
+var shifts = Migration{
+	Version: 7,
+	Name:    "shifts",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&shiftsShift{}, &shiftsTimeEntry{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&shiftsTimeEntry{}, &shiftsShift{})
+	},
+}
+
+type shiftsShift struct {
+	ID         uint      `gorm:"primaryKey"`
+	EmployeeID uint      `gorm:"not null;index:idx_shifts_employee_id"`
+	StartsAt   time.Time `gorm:"not null;index:idx_shifts_starts_at"`
+	EndsAt     time.Time `gorm:"not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (shiftsShift) TableName() string {
+	return "shifts"
+}
+
+type shiftsTimeEntry struct {
+	ID         uint      `gorm:"primaryKey"`
+	EmployeeID uint      `gorm:"not null;index:idx_time_entries_employee_id"`
+	ShiftID    *uint     `gorm:"index:idx_time_entries_shift_id"`
+	ClockInAt  time.Time `gorm:"not null;index:idx_time_entries_clock_in_at"`
+	ClockOutAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (shiftsTimeEntry) TableName() string {
+	return "time_entries"
+}
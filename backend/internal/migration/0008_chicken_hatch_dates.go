@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// chickenHatchDates заменяет возраст кур в месяцах датой вылупления. Возраст вводился
+// при добавлении курицы, поэтому дата вылупления оценивается от дня создания записи.
+var chickenHatchDates = Migration{
+	Version: 8,
+	Name:    "chicken_hatch_dates",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if err := migrator.AddColumn(&chickenHatchDatesNullable{}, "HatchDate"); err != nil {
+			return err
+		}
+
+		var rows []chickenHatchDatesNullable
+		if err := tx.Select("id", "age", "created_at").Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			year, month, day := row.CreatedAt.UTC().Date()
+			hatchDate := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).AddDate(0, -row.Age, 0)
+			err := tx.Model(&chickenHatchDatesNullable{}).Where("id = ?", row.ID).
+				Update("hatch_date", hatchDate).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := dropColumn(tx, &chickenHatchDatesChicken{}, "Age"); err != nil {
+			return err
+		}
+		return migrator.AlterColumn(&chickenHatchDatesChicken{}, "HatchDate")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&chickenHatchDatesChicken{}, "Age"); err != nil {
+			return err
+		}
+
+		var rows []chickenHatchDatesChicken
+		if err := tx.Select("id", "hatch_date").Find(&rows).Error; err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		for _, row := range rows {
+			hatchDate := row.HatchDate.UTC()
+			age := (now.Year()-hatchDate.Year())*12 + int(now.Month()-hatchDate.Month())
+			if now.Day() < hatchDate.Day() {
+				age--
+			}
+			err := tx.Model(&chickenHatchDatesChicken{}).Where("id = ?", row.ID).
+				Update("age", max(age, 1)).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return dropColumn(tx, &chickenHatchDatesChicken{}, "HatchDate")
+	},
+}
+
+type chickenHatchDatesChicken struct {
+	ID        uint      `gorm:"primaryKey"`
+	HatchDate time.Time `gorm:"not null"`
+	Age       int       `gorm:"not null;default:0"`
+}
+
+func (chickenHatchDatesChicken) TableName() string {
+	return "chickens"
+}
+
+// chickenHatchDatesNullable - та же таблица до заполнения hatch_date у старых строк
+type chickenHatchDatesNullable struct {
+	ID        uint `gorm:"primaryKey"`
+	HatchDate *time.Time
+	Age       int
+	CreatedAt time.Time
+}
+
+func (chickenHatchDatesNullable) TableName() string {
+	return "chickens"
+}
@@ -0,0 +1,64 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// weighIns добавляет журнал взвешиваний. Для каждой курицы сохраняется ее нынешний вес
+// как взвешивание в день последнего изменения записи.
+var weighIns = Migration{
+	Version: 9,
+	Name:    "weigh_ins",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&weighInsWeighIn{}); err != nil {
+			return err
+		}
+
+		var chickens []weighInsChicken
+		if err := tx.Find(&chickens).Error; err != nil {
+			return err
+		}
+		for _, chicken := range chickens {
+			year, month, day := chicken.UpdatedAt.UTC().Date()
+			weighIn := weighInsWeighIn{
+				ChickenID: chicken.ID,
+				Date:      time.Date(year, month, day, 0, 0, 0, 0, time.UTC),
+				Weight:    chicken.Weight,
+				CreatedAt: chicken.UpdatedAt,
+				UpdatedAt: chicken.UpdatedAt,
+			}
+			if err := tx.Create(&weighIn).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&weighInsWeighIn{})
+	},
+}
+
+type weighInsWeighIn struct {
+	ID        uint      `gorm:"primaryKey"`
+	ChickenID uint      `gorm:"not null;uniqueIndex:idx_weigh_ins_chicken_date"`
+	Date      time.Time `gorm:"not null;uniqueIndex:idx_weigh_ins_chicken_date"`
+	Weight    float64   `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (weighInsWeighIn) TableName() string {
+	return "weigh_ins"
+}
+
+type weighInsChicken struct {
+	ID        uint
+	Weight    float64
+	UpdatedAt time.Time
+}
+
+func (weighInsChicken) TableName() string {
+	return "chickens"
+}
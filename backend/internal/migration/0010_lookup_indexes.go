@@ -0,0 +1,56 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lookupIndexes индексирует колонки, по которым ищутся куры клетки и записи о яйцах.
+// employee_cages.employee_id проиндексирован миграцией employee_cage_periods.
+var lookupIndexes = Migration{
+	Version: 10,
+	Name:    "lookup_indexes",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if err := migrator.CreateIndex(&lookupIndexesChicken{}, "idx_chickens_cage_id"); err != nil {
+			return err
+		}
+		if err := migrator.CreateIndex(&lookupIndexesFarm{}, "idx_farm_records_date_cage_id"); err != nil {
+			return err
+		}
+		return migrator.CreateIndex(&lookupIndexesFarm{}, "idx_farm_records_chicken_id")
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if err := migrator.DropIndex(&lookupIndexesFarm{}, "idx_farm_records_chicken_id"); err != nil {
+			return err
+		}
+		if err := migrator.DropIndex(&lookupIndexesFarm{}, "idx_farm_records_date_cage_id"); err != nil {
+			return err
+		}
+		return migrator.DropIndex(&lookupIndexesChicken{}, "idx_chickens_cage_id")
+	},
+}
+
+type lookupIndexesChicken struct {
+	ID     uint `gorm:"primaryKey"`
+	CageID uint `gorm:"not null;index:idx_chickens_cage_id"`
+}
+
+func (lookupIndexesChicken) TableName() string {
+	return "chickens"
+}
+
+type lookupIndexesFarm struct {
+	ID        uint      `gorm:"primaryKey"`
+	Date      time.Time `gorm:"not null;index:idx_farm_records_date_cage_id,priority:1"`
+	CageID    uint      `gorm:"not null;index:idx_farm_records_date_cage_id,priority:2"`
+	ChickenID uint      `gorm:"not null;index:idx_farm_records_chicken_id"`
+}
+
+func (lookupIndexesFarm) TableName() string {
+	return "farm_records"
+}
@@ -0,0 +1,44 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// chickenSlots дает каждой курице место в клетке. Уникальная пара (клетка, место) не позволяет
+// двум одновременным запросам поселить кур на одно место. Существующие куры занимают места
+// по порядку добавления.
+var chickenSlots = Migration{
+	Version: 11,
+	Name:    "chicken_slots",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if err := migrator.AddColumn(&chickenSlotsChicken{}, "Slot"); err != nil {
+			return err
+		}
+
+		err := tx.Exec("UPDATE chickens SET slot = (SELECT COUNT(*) FROM chickens AS earlier" +
+			" WHERE earlier.cage_id = chickens.cage_id AND earlier.id <= chickens.id)").Error
+		if err != nil {
+			return err
+		}
+
+		return migrator.CreateIndex(&chickenSlotsChicken{}, "idx_chickens_cage_slot")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropIndex(&chickenSlotsChicken{}, "idx_chickens_cage_slot"); err != nil {
+			return err
+		}
+		return dropColumn(tx, &chickenSlotsChicken{}, "Slot")
+	},
+}
+
+type chickenSlotsChicken struct {
+	ID     uint `gorm:"primaryKey"`
+	CageID uint `gorm:"not null;uniqueIndex:idx_chickens_cage_slot"`
+	Slot   int  `gorm:"not null;default:0;uniqueIndex:idx_chickens_cage_slot"`
+}
+
+func (chickenSlotsChicken) TableName() string {
+	return "chickens"
+}
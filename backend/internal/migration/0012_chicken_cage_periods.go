@@ -0,0 +1,64 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// chickenCagePeriods добавляет историю клеток курицы, по которой проверяются записи о яйцах
+// задним числом. Прежние переселения не сохранялись, поэтому каждая курица получает один
+// период в нынешней клетке со дня добавления.
+var chickenCagePeriods = Migration{
+	Version: 12,
+	Name:    "chicken_cage_periods",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&chickenCagePeriodsChickenCage{}); err != nil {
+			return err
+		}
+
+		var chickens []chickenCagePeriodsChicken
+		if err := tx.Find(&chickens).Error; err != nil {
+			return err
+		}
+		for _, chicken := range chickens {
+			year, month, day := chicken.CreatedAt.UTC().Date()
+			period := chickenCagePeriodsChickenCage{
+				ChickenID: chicken.ID,
+				CageID:    chicken.CageID,
+				ValidFrom: time.Date(year, month, day, 0, 0, 0, 0, time.UTC),
+			}
+			if err := tx.Create(&period).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&chickenCagePeriodsChickenCage{})
+	},
+}
+
+type chickenCagePeriodsChickenCage struct {
+	ID        uint      `gorm:"primaryKey"`
+	ChickenID uint      `gorm:"not null;index:idx_chicken_cages_chicken_id"`
+	CageID    uint      `gorm:"not null;index:idx_chicken_cages_cage_id"`
+	ValidFrom time.Time `gorm:"not null"`
+	ValidTo   *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (chickenCagePeriodsChickenCage) TableName() string {
+	return "chicken_cages"
+}
+
+type chickenCagePeriodsChicken struct {
+	ID        uint
+	CageID    uint
+	CreatedAt time.Time
+}
+
+func (chickenCagePeriodsChicken) TableName() string {
+	return "chickens"
+}
@@ -0,0 +1,221 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migration - одно изменение схемы. Up и Down выполняются в транзакции вместе с записью
+// в schema_migrations. Миграции описывают таблицы своими копиями структур, а не типами
+// из пакета model, чтобы последующие изменения моделей не меняли уже выпущенные миграции.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// migrations - все миграции по возрастанию версии; новые добавляются в конец
+var migrations = []Migration{
+	baseline,
+	users,
+	passportEncryption,
+	employeeCagePeriods,
+	workloadLimits,
+	payroll,
+	shifts,
+	chickenHatchDates,
+	weighIns,
+	lookupIndexes,
+	chickenSlots,
+	chickenCagePeriods,
+}
+
+// SchemaMigration - запись о примененной миграции
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status - состояние миграции в базе; AppliedAt равен nil, если миграция еще не применена
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator создает мигратор со всеми миграциями приложения
+func NewMigrator(db *gorm.DB) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// Up применяет все еще не примененные миграции и возвращает их список
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		if err := m.run(migration, true); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down откатывает steps последних примененных миграций и возвращает их список
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	var done []Migration
+	for _, version := range versions {
+		if len(done) == steps {
+			break
+		}
+
+		migration, ok := m.find(version)
+		if !ok {
+			return done, fmt.Errorf("migration %04d is applied but unknown to this build", version)
+		}
+
+		if err := m.run(migration, false); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Status возвращает состояние всех миграций по возрастанию версии
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) applied() (map[int]SchemaMigration, error) {
+	if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var records []SchemaMigration
+	if err := m.db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+func (m *Migrator) run(migration Migration, up bool) error {
+	tx := m.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if up {
+		if err := migration.Up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %04d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		record := SchemaMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now(),
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else {
+		if err := migration.Down(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("rollback of migration %04d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		if err := tx.Delete(&SchemaMigration{}, migration.Version).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// dropColumn удаляет столбец через ALTER TABLE ... DROP COLUMN. Migrator().DropColumn в SQLite
+// пересоздает таблицу и при этом теряет ее индексы, поэтому миграции используют эту функцию.
+// Индексы по удаляемому столбцу нужно удалить заранее.
+func dropColumn(tx *gorm.DB, value interface{}, name string) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(value); err != nil {
+		return err
+	}
+
+	column := name
+	if field := stmt.Schema.LookUpField(name); field != nil {
+		column = field.DBName
+	}
+
+	return tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: stmt.Table}, clause.Column{Name: column}).Error
+}
@@ -2,15 +2,20 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Chicken struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
-	CageID      uint      `json:"cage_id" gorm:"not null"`
-	Weight      float64   `json:"weight" gorm:"not null"`        // вес в килограммах
-	Age         int       `json:"age" gorm:"not null"`           // возраст в месяцах
-	EggPerMonth int       `json:"egg_per_month" gorm:"not null"` // количество яиц в месяц
-	Breed       string    `json:"breed" gorm:"not null"`
+	CageID      uint      `json:"cage_id" gorm:"not null;index;uniqueIndex:idx_chickens_cage_slot" binding:"required"`
+	Slot        int       `json:"slot" gorm:"not null;uniqueIndex:idx_chickens_cage_slot"` // место в клетке, назначается при заселении
+	Weight      float64   `json:"weight" gorm:"not null" binding:"gt=0,lte=15"`            // вес в килограммах
+	HatchDate   Date      `json:"hatch_date" gorm:"not null"`                              // день вылупления
+	Age         int       `json:"age" gorm:"-" binding:"omitempty,gt=0,lte=240"`           // возраст в полных месяцах, считается по HatchDate
+	EggPerMonth int       `json:"egg_per_month" gorm:"not null" binding:"gte=0,lte=31"`    // количество яиц в месяц
+	Breed       string    `json:"breed" gorm:"not null" binding:"required,max=100"`
+	LayingRate  *float64  `json:"laying_rate,omitempty" gorm:"-"` // яиц за последние 30 дней по записям farm_records
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -18,3 +23,60 @@ type Chicken struct {
 func (Chicken) TableName() string {
 	return "chickens"
 }
+
+// AfterFind заполняет возраст у загруженной из базы курицы
+func (c *Chicken) AfterFind(tx *gorm.DB) error {
+	c.Age = AgeInMonths(c.HatchDate.Time, time.Now())
+	return nil
+}
+
+// AgeInMonths возвращает число полных месяцев от hatchDate до now
+func AgeInMonths(hatchDate, now time.Time) int {
+	hatchYear, hatchMonth, hatchDay := hatchDate.UTC().Date()
+	year, month, day := now.UTC().Date()
+
+	months := (year-hatchYear)*12 + int(month-hatchMonth)
+	if day < hatchDay {
+		months--
+	}
+	return max(months, 0)
+}
+
+// HatchDateForAge возвращает самую позднюю дату вылупления, при которой на день now
+// курице исполнилось age полных месяцев. Если в том месяце нет такого числа, берется его последний день.
+func HatchDateForAge(age int, now time.Time) time.Time {
+	year, month, day := now.UTC().Date()
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).AddDate(0, -age, 0)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+// WeighIn - взвешивание курицы. Текущий вес курицы - вес из последнего взвешивания.
+type WeighIn struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ChickenID uint      `json:"chicken_id" gorm:"not null;uniqueIndex:idx_weigh_ins_chicken_date"`
+	Date      time.Time `json:"date" gorm:"not null;uniqueIndex:idx_weigh_ins_chicken_date"` // не больше одного взвешивания в день
+	Weight    float64   `json:"weight" gorm:"not null"`                                      // вес в килограммах
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (WeighIn) TableName() string {
+	return "weigh_ins"
+}
+
+// ChickenCage - период, в который курица жила в клетке: [ValidFrom, ValidTo).
+// У нынешней клетки ValidTo = nil.
+type ChickenCage struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	ChickenID uint       `json:"chicken_id" gorm:"not null;index"`
+	CageID    uint       `json:"cage_id" gorm:"not null;index"`
+	ValidFrom time.Time  `json:"valid_from" gorm:"not null"`
+	ValidTo   *time.Time `json:"valid_to"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (ChickenCage) TableName() string {
+	return "chicken_cages"
+}
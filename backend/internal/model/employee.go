@@ -6,20 +6,26 @@ import (
 
 type Employee struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
-	FullName     string    `json:"full_name" gorm:"not null"`
-	PassportData string    `json:"passport_data" gorm:"not null;uniqueIndex"`
-	Salary       float64   `json:"salary" gorm:"not null"`
-	Cages        []uint    `json:"cages" gorm:"-"` // Список ID клеток
+	FullName     string    `json:"full_name" gorm:"not null" binding:"required,max=255"`
+	PassportData string    `json:"passport_data" gorm:"not null" binding:"omitempty,passport"` // серия и номер: "1234 567890", в базе зашифрованы
+	PassportHash *string   `json:"-" gorm:"uniqueIndex"`                                       // HMAC номера для проверки уникальности
+	Salary       float64   `json:"salary" gorm:"not null" binding:"gte=0"`
+	MaxChickens  int       `json:"max_chickens" gorm:"not null;default:0" binding:"gte=0"` // предельная нагрузка, 0 - без ограничения
+	Cages        []uint    `json:"cages" gorm:"-" binding:"dive,gt=0"`                     // Список ID клеток
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// EmployeeCage - период, в который сотрудник обслуживал клетку: [ValidFrom, ValidTo).
+// Действующее закрепление имеет ValidTo = nil.
 type EmployeeCage struct {
-	ID         uint      `json:"id" gorm:"primaryKey"`
-	EmployeeID uint      `json:"employee_id" gorm:"not null"`
-	CageID     uint      `json:"cage_id" gorm:"not null"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	EmployeeID uint       `json:"employee_id" gorm:"not null;index"`
+	CageID     uint       `json:"cage_id" gorm:"not null;index"`
+	ValidFrom  time.Time  `json:"valid_from" gorm:"not null"`
+	ValidTo    *time.Time `json:"valid_to"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
 }
 
 func (Employee) TableName() string {
@@ -6,9 +6,9 @@ import (
 
 type Farm struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
-	Date      time.Time `json:"date" gorm:"not null"`
-	CageID    uint      `json:"cage_id" gorm:"not null"`
-	ChickenID uint      `json:"chicken_id" gorm:"not null"`
+	Date      time.Time `json:"date" gorm:"not null;index:idx_farm_records_date_cage_id,priority:1"`
+	CageID    uint      `json:"cage_id" gorm:"not null;index:idx_farm_records_date_cage_id,priority:2"`
+	ChickenID uint      `json:"chicken_id" gorm:"not null;index"`
 	HasEgg    bool      `json:"has_egg" gorm:"not null"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -17,6 +17,8 @@ type Farm struct {
 type Cage struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	Number    int       `json:"number" gorm:"not null;uniqueIndex"`
+	Capacity  int       `json:"capacity" gorm:"not null;default:1"` // сколько кур помещается в клетку
+	Row       string    `json:"row" gorm:"not null;default:''"`     // клетки одного ряда обслуживает один сотрудник
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -41,6 +43,40 @@ func (ConfigParam) TableName() string {
 	return "config_params"
 }
 
-func GetEggPrice() float64 {
-	return 10.0
+// EggPrice - цена яйца, действующая с указанной даты до начала следующего периода
+type EggPrice struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	Price         float64   `json:"price" gorm:"not null"`
+	EffectiveFrom time.Time `json:"effective_from" gorm:"not null;uniqueIndex"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
+
+func (EggPrice) TableName() string {
+	return "egg_prices"
+}
+
+// Ключи параметров конфигурации
+const (
+	ConfigEggPrice             = "egg_price"
+	ConfigCageAssignmentPolicy = "cage_assignment_policy"
+	ConfigPayrollEggTarget     = "payroll_egg_target"        // яиц в месяц, сверх которых начисляется премия
+	ConfigPayrollEggBonus      = "payroll_egg_bonus"         // премия за каждое яйцо сверх плана
+	ConfigWeightLossAlert      = "weight_loss_alert_percent" // потеря веса между взвешиваниями, о которой нужно предупредить
+	ConfigEggRateSource        = "egg_rate_source"           // откуда берется яйценоскость для рейтингов кур
+)
+
+// Значения egg_rate_source
+const (
+	EggRateManual   = "manual"   // egg_per_month, введенное вручную
+	EggRateComputed = "computed" // яйца за последние 30 дней по записям farm_records
+)
+
+// Значения cage_assignment_policy
+const (
+	CageAssignmentShared    = "shared"    // клетку могут обслуживать несколько сотрудников
+	CageAssignmentExclusive = "exclusive" // у клетки не больше одного сотрудника
+)
+
+// DefaultEggPrice используется, если цена яйца не задана в config_params
+const DefaultEggPrice = 10.0
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+)
+
+// Статусы расчетных листков
+const (
+	PayslipDraft     = "draft"     // пересчитывается при каждом расчете периода
+	PayslipFinalized = "finalized" // утвержден, больше не меняется
+)
+
+// Виды строк расчетного листка
+const (
+	PayslipLineSalary   = "base_salary"
+	PayslipLineEggBonus = "egg_bonus"
+	PayslipLineAbsence  = "absence_deduction"
+)
+
+// Payslip - расчетный листок сотрудника за месяц. Данные сотрудника копируются в листок,
+// чтобы утвержденный листок не зависел от последующих изменений.
+type Payslip struct {
+	ID           uint          `json:"id" gorm:"primaryKey"`
+	Period       string        `json:"period" gorm:"not null;uniqueIndex:idx_payslips_period_employee"` // месяц: "2024-03"
+	EmployeeID   uint          `json:"employee_id" gorm:"not null;uniqueIndex:idx_payslips_period_employee"`
+	EmployeeName string        `json:"employee_name" gorm:"not null"`
+	EggCount     int           `json:"egg_count" gorm:"not null"`
+	AbsenceDays  int           `json:"absence_days" gorm:"not null"`
+	BaseSalary   float64       `json:"base_salary" gorm:"not null"`
+	Bonus        float64       `json:"bonus" gorm:"not null"`
+	Deductions   float64       `json:"deductions" gorm:"not null"`
+	Total        float64       `json:"total" gorm:"not null"`
+	Status       string        `json:"status" gorm:"not null"`
+	FinalizedAt  *time.Time    `json:"finalized_at"`
+	Lines        []PayslipLine `json:"lines" gorm:"foreignKey:PayslipID"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+func (Payslip) TableName() string {
+	return "payslips"
+}
+
+// PayslipLine - начисление или удержание: Amount = Quantity * Rate, удержания отрицательны
+type PayslipLine struct {
+	ID          uint    `json:"id" gorm:"primaryKey"`
+	PayslipID   uint    `json:"payslip_id" gorm:"not null;index"`
+	Kind        string  `json:"kind" gorm:"not null"`
+	Description string  `json:"description" gorm:"not null"`
+	Quantity    float64 `json:"quantity" gorm:"not null"`
+	Rate        float64 `json:"rate" gorm:"not null"`
+	Amount      float64 `json:"amount" gorm:"not null"`
+}
+
+func (PayslipLine) TableName() string {
+	return "payslip_lines"
+}
+
+// Absence - день, пропущенный сотрудником; за него удерживается часть оклада
+type Absence struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	EmployeeID uint      `json:"employee_id" gorm:"not null;uniqueIndex:idx_absences_employee_date"`
+	Date       time.Time `json:"date" gorm:"not null;uniqueIndex:idx_absences_employee_date"`
+	Reason     string    `json:"reason" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (Absence) TableName() string {
+	return "absences"
+}
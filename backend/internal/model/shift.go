@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+)
+
+// Shift - запланированная смена сотрудника
+type Shift struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	EmployeeID uint      `json:"employee_id" gorm:"not null;index"`
+	StartsAt   time.Time `json:"starts_at" gorm:"not null;index"`
+	EndsAt     time.Time `json:"ends_at" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (Shift) TableName() string {
+	return "shifts"
+}
+
+// TimeEntry - фактическое присутствие на ферме: от прихода до ухода.
+// Пока сотрудник не ушел, ClockOutAt равен nil.
+type TimeEntry struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	EmployeeID uint       `json:"employee_id" gorm:"not null;index"`
+	ShiftID    *uint      `json:"shift_id" gorm:"index"` // смена, на которую пришел сотрудник; nil - работа вне графика
+	ClockInAt  time.Time  `json:"clock_in_at" gorm:"not null;index"`
+	ClockOutAt *time.Time `json:"clock_out_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (TimeEntry) TableName() string {
+	return "time_entries"
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+)
+
+type Role string
+
+// Роли пользователей
+const (
+	RoleAdmin   Role = "admin"   // все операции, включая управление пользователями
+	RoleManager Role = "manager" // справочники, сотрудники и отчеты
+	RoleWorker  Role = "worker"  // записи о сборе яиц в своих клетках
+)
+
+type User struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Username         string    `json:"username" gorm:"not null;uniqueIndex"`
+	PasswordHash     string    `json:"-" gorm:"not null"` // bcrypt
+	Role             Role      `json:"role" gorm:"not null"`
+	EmployeeID       *uint     `json:"employee_id"`                                      // сотрудник, чьи клетки доступны работнику
+	CanViewPassports bool      `json:"can_view_passports" gorm:"not null;default:false"` // иначе паспорта в ответах маскируются
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func (User) TableName() string {
+	return "users"
+}
+
+// Session - выданный при входе токен. Хранится только SHA-256 от токена.
+type Session struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	TokenHash string    `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
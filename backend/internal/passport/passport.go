@@ -0,0 +1,124 @@
+package passport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize - длина ключа из конфигурации в байтах
+const KeySize = 32
+
+// префикс зашифрованного значения; открытый номер вида "1234 567890" с ним не совпадет
+const encryptedPrefix = "enc:v1:"
+
+// Cipher шифрует номера паспортов (AES-256-GCM) и считает их HMAC-SHA256 для проверки
+// уникальности. Ключи шифрования и хэширования выводятся из одного ключа конфигурации.
+type Cipher struct {
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("passport key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(deriveKey(key, "passport-encryption"))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{
+		aead:    aead,
+		hashKey: deriveKey(key, "passport-hash"),
+	}, nil
+}
+
+// ParseKey декодирует ключ из конфигурации, записанный в base64
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("passport key must be base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("passport key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+func (c *Cipher) Encrypt(number string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(number), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt расшифровывает значение из базы. Значения, сохраненные до появления шифрования,
+// возвращаются как есть.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decrypt passport: %w", err)
+	}
+
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("decrypt passport: ciphertext is too short")
+	}
+
+	number, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt passport: %w", err)
+	}
+
+	return string(number), nil
+}
+
+// Hash возвращает детерминированный хэш номера: одинаковые номера дают одинаковый хэш,
+// но без ключа по хэшу номер не подобрать
+func (c *Cipher) Hash(number string) string {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(number))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted сообщает, зашифровано ли значение из базы
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Mask оставляет видимыми серию и две последние цифры номера: "1234 567890" -> "1234 ****90"
+func Mask(number string) string {
+	series, digits, ok := strings.Cut(number, " ")
+	if !ok || len(digits) <= 2 {
+		return strings.Repeat("*", len(number))
+	}
+	return series + " " + strings.Repeat("*", len(digits)-2) + digits[len(digits)-2:]
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -1,11 +1,21 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"chicken-farm/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrCageFull - число кур в клетке уже равно ее вместимости
+var ErrCageFull = errors.New("cage is full")
+
+// ErrCapacityBelowOccupancy - новая вместимость клетки меньше числа кур в ней
+var ErrCapacityBelowOccupancy = errors.New("capacity is less than the number of chickens in the cage")
+
 type ChickenRepository struct {
 	db *gorm.DB
 }
@@ -14,8 +24,77 @@ func NewChickenRepository(db *gorm.DB) *ChickenRepository {
 	return &ChickenRepository{db: db}
 }
 
-func (r *ChickenRepository) Create(chicken *model.Chicken) error {
-	return r.db.Create(chicken).Error
+// Create селит курицу на свободное место клетки с дня date и записывает ее первое взвешивание в этот день.
+// Если клетки нет, возвращает gorm.ErrRecordNotFound, если в ней нет места - ErrCageFull.
+func (r *ChickenRepository) Create(chicken *model.Chicken, date time.Time) error {
+	tx := r.db.Begin()
+
+	if err := takeSlot(tx, chicken); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Create(chicken).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	period := model.ChickenCage{ChickenID: chicken.ID, CageID: chicken.CageID, ValidFrom: date}
+	if err := tx.Create(&period).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	weighIn := model.WeighIn{ChickenID: chicken.ID, Date: date, Weight: chicken.Weight}
+	if err := tx.Create(&weighIn).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// lockCage загружает клетку и блокирует ее строку до конца транзакции (SELECT ... FOR UPDATE).
+// Все, кто меняет число кур в клетке или ее вместимость, берут эту блокировку, поэтому
+// их проверки и записи выполняются по очереди. SQLite блокировок строк не знает:
+// там транзакции и так идут по одной благодаря _txlock=immediate.
+func lockCage(tx *gorm.DB, cageID uint) (*model.Cage, error) {
+	var cage model.Cage
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cage, cageID).Error; err != nil {
+		return nil, err
+	}
+	return &cage, nil
+}
+
+// takeSlot назначает курице наименьшее свободное место в ее клетке под блокировкой клетки;
+// уникальный индекс idx_chickens_cage_slot страхует от записи в обход этой функции
+func takeSlot(tx *gorm.DB, chicken *model.Chicken) error {
+	cage, err := lockCage(tx, chicken.CageID)
+	if err != nil {
+		return err
+	}
+
+	var taken []int
+	err = tx.Model(&model.Chicken{}).
+		Where("cage_id = ? AND id <> ?", chicken.CageID, chicken.ID).
+		Order("slot").
+		Pluck("slot", &taken).Error
+	if err != nil {
+		return err
+	}
+	if len(taken) >= cage.Capacity {
+		return ErrCageFull
+	}
+
+	// после уменьшения вместимости места могут идти с пропусками
+	slot := 1
+	for _, takenSlot := range taken {
+		if takenSlot == slot {
+			slot++
+		}
+	}
+	chicken.Slot = slot
+	return nil
 }
 
 func (r *ChickenRepository) GetByID(id uint) (*model.Chicken, error) {
@@ -36,53 +115,277 @@ func (r *ChickenRepository) GetByCageID(cageID uint) (*model.Chicken, error) {
 	return &chicken, nil
 }
 
+func (r *ChickenRepository) GetAllByCageID(cageID uint) ([]model.Chicken, error) {
+	var chickens []model.Chicken
+	err := r.db.Where("cage_id = ?", cageID).Find(&chickens).Error
+	return chickens, err
+}
+
+func (r *ChickenRepository) CountByCageID(cageID uint) (int, error) {
+	var count int64
+	err := r.db.Model(&model.Chicken{}).Where("cage_id = ?", cageID).Count(&count).Error
+	return int(count), err
+}
+
 func (r *ChickenRepository) GetAll() ([]model.Chicken, error) {
 	var chickens []model.Chicken
 	err := r.db.Find(&chickens).Error
 	return chickens, err
 }
 
+// ChickenFilter - условия списка кур; пустые поля не ограничивают выборку
+type ChickenFilter struct {
+	Breed     string
+	CageID    uint
+	MinWeight *float64
+	MaxWeight *float64
+}
+
+// List возвращает страницу кур под фильтром и общее число подходящих кур
+func (r *ChickenRepository) List(filter ChickenFilter, list ListQuery) ([]model.Chicken, int64, error) {
+	query := r.db.Model(&model.Chicken{})
+	if filter.Breed != "" {
+		query = query.Where("breed = ?", filter.Breed)
+	}
+	if filter.CageID != 0 {
+		query = query.Where("cage_id = ?", filter.CageID)
+	}
+	if filter.MinWeight != nil {
+		query = query.Where("weight >= ?", *filter.MinWeight)
+	}
+	if filter.MaxWeight != nil {
+		query = query.Where("weight <= ?", *filter.MaxWeight)
+	}
+
+	var chickens []model.Chicken
+	total, err := findPage(query, list, ChickenSortColumns, &chickens)
+	return chickens, total, err
+}
+
 func (r *ChickenRepository) GetByWeightAndAge(weight float64, age int) ([]model.Chicken, error) {
+	from, to := hatchDateRange(age, time.Now())
 	var chickens []model.Chicken
-	err := r.db.Where("weight = ? AND age = ?", weight, age).Find(&chickens).Error
+	err := r.db.Where("weight = ? AND hatch_date > ? AND hatch_date <= ?", weight, from, to).Find(&chickens).Error
 	return chickens, err
 }
 
-func (r *ChickenRepository) Update(chicken *model.Chicken) error {
-	return r.db.Save(chicken).Error
+// Update сохраняет курицу; при переселении в другую клетку она занимает там свободное место
+// (ошибки как у Create), а ее период в прежней клетке закрывается днем since. Если задано
+// взвешивание, оно записывается в ту же транзакцию, заменяя взвешивание в тот же день.
+func (r *ChickenRepository) Update(chicken *model.Chicken, weighIn *model.WeighIn, since time.Time) error {
+	tx := r.db.Begin()
+
+	var current model.Chicken
+	if err := tx.Select("id", "cage_id", "slot").First(&current, chicken.ID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if current.CageID == chicken.CageID {
+		chicken.Slot = current.Slot
+	} else {
+		if err := takeSlot(tx, chicken); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := moveToCage(tx, chicken.ID, chicken.CageID, since); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Save(chicken).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if weighIn != nil {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chicken_id"}, {Name: "date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"weight", "updated_at"}),
+		}).Create(weighIn).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
 }
 
+// Delete удаляет курицу вместе с ее взвешиваниями и историей клеток
 func (r *ChickenRepository) Delete(id uint) error {
-	return r.db.Delete(&model.Chicken{}, id).Error
+	tx := r.db.Begin()
+
+	if err := tx.Where("chicken_id = ?", id).Delete(&model.WeighIn{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("chicken_id = ?", id).Delete(&model.ChickenCage{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Delete(&model.Chicken{}, id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
 }
 
-func (r *ChickenRepository) GetAvgEggsByWeightAndAge(weight float64, age int) (float64, error) {
-	var avgEggs float64
-	err := r.db.Model(&model.Chicken{}).
-		Select("AVG(egg_per_month) as avg_eggs").
-		Where("weight = ? AND age = ?", weight, age).
-		Scan(&avgEggs).Error
+// moveToCage закрывает нынешний период курицы днем since и открывает период в клетке cageID.
+// Период, начавшийся в тот же день, удаляется, как и у закреплений сотрудников.
+func moveToCage(tx *gorm.DB, chickenID, cageID uint, since time.Time) error {
+	var current []model.ChickenCage
+	if err := tx.Where("chicken_id = ? AND valid_to IS NULL", chickenID).Find(&current).Error; err != nil {
+		return err
+	}
+	for _, period := range current {
+		var err error
+		if period.ValidFrom.Before(since) {
+			err = tx.Model(&model.ChickenCage{}).Where("id = ?", period.ID).Update("valid_to", since).Error
+		} else {
+			err = tx.Delete(&model.ChickenCage{}, period.ID).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
 
-	return avgEggs, err
+	return tx.Create(&model.ChickenCage{ChickenID: chickenID, CageID: cageID, ValidFrom: since}).Error
 }
 
-func (r *ChickenRepository) GetChickensWithLowProductivity() ([]model.Chicken, error) {
-	var avgEggPerMonth float64
-	err := r.db.Model(&model.Chicken{}).Select("AVG(egg_per_month)").Scan(&avgEggPerMonth).Error
+// GetCageOnDate возвращает клетку, в которой курица жила в день date.
+// Если курицы тогда еще не было, возвращает gorm.ErrRecordNotFound.
+func (r *ChickenRepository) GetCageOnDate(chickenID uint, date time.Time) (uint, error) {
+	var period model.ChickenCage
+	err := r.db.Where("chicken_id = ? AND valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", chickenID, date, date).
+		First(&period).Error
+	if err != nil {
+		return 0, err
+	}
+	return period.CageID, nil
+}
+
+// GetCagesOnDate возвращает клетки всех кур в день date по ID курицы
+func (r *ChickenRepository) GetCagesOnDate(date time.Time) (map[uint]uint, error) {
+	var periods []model.ChickenCage
+	err := r.db.Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", date, date).Find(&periods).Error
 	if err != nil {
 		return nil, err
 	}
 
-	var chickens []model.Chicken
-	err = r.db.Where("egg_per_month < ?", avgEggPerMonth).Find(&chickens).Error
-	return chickens, err
+	cages := make(map[uint]uint, len(periods))
+	for _, period := range periods {
+		cages[period.ChickenID] = period.CageID
+	}
+	return cages, nil
 }
 
-func (r *ChickenRepository) GetMostProductiveChicken() (*model.Chicken, error) {
-	var chicken model.Chicken
-	err := r.db.Order("egg_per_month DESC").First(&chicken).Error
+// CreateWeighIns записывает взвешивания в одной транзакции и обновляет текущий вес кур
+// по их последним взвешиваниям
+func (r *ChickenRepository) CreateWeighIns(weighIns []model.WeighIn) error {
+	tx := r.db.Begin()
+
+	chickenIDs := make([]uint, 0, len(weighIns))
+	for i := range weighIns {
+		if err := tx.Create(&weighIns[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		chickenIDs = append(chickenIDs, weighIns[i].ChickenID)
+	}
+
+	latest := tx.Model(&model.WeighIn{}).Select("weight").
+		Where("weigh_ins.chicken_id = chickens.id").
+		Order("date DESC").
+		Limit(1)
+	err := tx.Model(&model.Chicken{}).Where("id IN ?", chickenIDs).Update("weight", latest).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (r *ChickenRepository) GetWeighInByDate(chickenID uint, date time.Time) (*model.WeighIn, error) {
+	var weighIn model.WeighIn
+	err := r.db.Where("chicken_id = ? AND date = ?", chickenID, date).First(&weighIn).Error
 	if err != nil {
 		return nil, err
 	}
-	return &chicken, nil
+	return &weighIn, nil
+}
+
+// GetPreviousWeighIn возвращает последнее взвешивание курицы до дня date
+func (r *ChickenRepository) GetPreviousWeighIn(chickenID uint, date time.Time) (*model.WeighIn, error) {
+	var weighIn model.WeighIn
+	err := r.db.Where("chicken_id = ? AND date < ?", chickenID, date).Order("date DESC").First(&weighIn).Error
+	if err != nil {
+		return nil, err
+	}
+	return &weighIn, nil
+}
+
+// GetNextWeighIn возвращает первое взвешивание курицы после дня date
+func (r *ChickenRepository) GetNextWeighIn(chickenID uint, date time.Time) (*model.WeighIn, error) {
+	var weighIn model.WeighIn
+	err := r.db.Where("chicken_id = ? AND date > ?", chickenID, date).Order("date").First(&weighIn).Error
+	if err != nil {
+		return nil, err
+	}
+	return &weighIn, nil
+}
+
+type WeighInFilter struct {
+	ChickenID uint
+	StartDate *time.Time
+	EndDate   *time.Time // включительно
+}
+
+func (r *ChickenRepository) FindWeighIns(filter WeighInFilter) ([]model.WeighIn, error) {
+	query := r.db.Model(&model.WeighIn{})
+	if filter.ChickenID != 0 {
+		query = query.Where("chicken_id = ?", filter.ChickenID)
+	}
+	if filter.StartDate != nil {
+		query = query.Where("date >= ?", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		query = query.Where("date <= ?", *filter.EndDate)
+	}
+
+	var weighIns []model.WeighIn
+	err := query.Order("chicken_id, date").Find(&weighIns).Error
+	return weighIns, err
+}
+
+// GetWeighInsWithHistory возвращает взвешивания кур, которых взвешивали в полуинтервале [start, end),
+// вместе со всеми их более ранними взвешиваниями - по ним считается изменение веса
+func (r *ChickenRepository) GetWeighInsWithHistory(start, end time.Time) ([]model.WeighIn, error) {
+	weighed := r.db.Model(&model.WeighIn{}).Select("chicken_id").Where("date >= ? AND date < ?", start, end)
+
+	var weighIns []model.WeighIn
+	err := r.db.Where("chicken_id IN (?) AND date < ?", weighed, end).
+		Order("chicken_id, date").
+		Find(&weighIns).Error
+	return weighIns, err
+}
+
+func (r *ChickenRepository) GetAvgEggsByWeightAndAge(weight float64, age int) (float64, error) {
+	from, to := hatchDateRange(age, time.Now())
+	var avgEggs float64
+	err := r.db.Model(&model.Chicken{}).
+		Select("COALESCE(AVG(egg_per_month), 0) as avg_eggs").
+		Where("weight = ? AND hatch_date > ? AND hatch_date <= ?", weight, from, to).
+		Scan(&avgEggs).Error
+
+	return avgEggs, err
+}
+
+// hatchDateRange возвращает полуинтервал (from, to] дат вылупления кур,
+// которым на день now исполнилось ровно age полных месяцев
+func hatchDateRange(age int, now time.Time) (from, to time.Time) {
+	return model.HatchDateForAge(age+1, now), model.HatchDateForAge(age, now)
 }
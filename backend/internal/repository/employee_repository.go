@@ -1,20 +1,40 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
 	"chicken-farm/internal/model"
+	"chicken-farm/internal/passport"
 
 	"gorm.io/gorm"
 )
 
+// ErrCageTaken - при исключительном закреплении клетка уже закреплена за другим сотрудником
+var ErrCageTaken = errors.New("cage is already assigned to another employee")
+
+// EmployeeRepository хранит паспорта зашифрованными: методы принимают и возвращают
+// сотрудников с открытым номером, шифрование и расшифровка происходят здесь
 type EmployeeRepository struct {
-	db *gorm.DB
+	db        *gorm.DB
+	passports *passport.Cipher
 }
 
-func NewEmployeeRepository(db *gorm.DB) *EmployeeRepository {
-	return &EmployeeRepository{db: db}
+func NewEmployeeRepository(db *gorm.DB, passports *passport.Cipher) *EmployeeRepository {
+	return &EmployeeRepository{db: db, passports: passports}
 }
 
-func (r *EmployeeRepository) Create(employee *model.Employee) error {
+// Create сохраняет сотрудника и закрепляет за ним клетки начиная с дня since.
+// При exclusive клетки, закрепленные за другими, не выдаются: возвращается ErrCageTaken.
+func (r *EmployeeRepository) Create(employee *model.Employee, since time.Time, exclusive bool) error {
+	restore, err := r.sealPassport(employee)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Begin()
 
 	if err := tx.Create(employee).Error; err != nil {
@@ -22,15 +42,14 @@ func (r *EmployeeRepository) Create(employee *model.Employee) error {
 		return err
 	}
 
-	for _, cageID := range employee.Cages {
-		employeeCage := model.EmployeeCage{
-			EmployeeID: employee.ID,
-			CageID:     cageID,
-		}
-		if err := tx.Create(&employeeCage).Error; err != nil {
-			tx.Rollback()
-			return err
-		}
+	if err := lockFreeCages(tx, employee.ID, employee.Cages, exclusive); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := openAssignments(tx, employee.ID, employee.Cages, since); err != nil {
+		tx.Rollback()
+		return err
 	}
 
 	return tx.Commit().Error
@@ -43,15 +62,15 @@ func (r *EmployeeRepository) GetByID(id uint) (*model.Employee, error) {
 		return nil, err
 	}
 
-	var employeeCages []model.EmployeeCage
-	if err := r.db.Where("employee_id = ?", employee.ID).Find(&employeeCages).Error; err != nil {
+	if err := r.openPassport(&employee); err != nil {
 		return nil, err
 	}
 
-	employee.Cages = make([]uint, len(employeeCages))
-	for i, ec := range employeeCages {
-		employee.Cages[i] = ec.CageID
+	cages, err := activeCageIDs(r.db, employee.ID)
+	if err != nil {
+		return nil, err
 	}
+	employee.Cages = cages
 
 	return &employee, nil
 }
@@ -63,40 +82,196 @@ func (r *EmployeeRepository) GetAll() ([]model.Employee, error) {
 		return nil, err
 	}
 
+	if err := r.fillEmployees(employees, true); err != nil {
+		return nil, err
+	}
+	return employees, nil
+}
+
+// fillEmployees расшифровывает паспорта и загружает текущие клетки сотрудников одним запросом
+func (r *EmployeeRepository) fillEmployees(employees []model.Employee, all bool) error {
+	var employeeIDs []uint
+	if !all {
+		employeeIDs = make([]uint, len(employees))
+		for i, employee := range employees {
+			employeeIDs[i] = employee.ID
+		}
+	}
+
+	cages, err := activeCagesByEmployee(r.db, employeeIDs)
+	if err != nil {
+		return err
+	}
+
 	for i := range employees {
-		var employeeCages []model.EmployeeCage
-		if err := r.db.Where("employee_id = ?", employees[i].ID).Find(&employeeCages).Error; err != nil {
-			return nil, err
+		if err := r.openPassport(&employees[i]); err != nil {
+			return err
 		}
 
-		employees[i].Cages = make([]uint, len(employeeCages))
-		for j, ec := range employeeCages {
-			employees[i].Cages[j] = ec.CageID
+		employees[i].Cages = cages[employees[i].ID]
+		if employees[i].Cages == nil {
+			employees[i].Cages = []uint{}
+		}
+	}
+	return nil
+}
+
+// EmployeeFilter - условия списка сотрудников; пустые поля не ограничивают выборку
+type EmployeeFilter struct {
+	MinSalary *float64
+	MaxSalary *float64
+}
+
+// List возвращает страницу сотрудников под фильтром и общее число подходящих сотрудников
+func (r *EmployeeRepository) List(filter EmployeeFilter, list ListQuery) ([]model.Employee, int64, error) {
+	query := r.db.Model(&model.Employee{})
+	if filter.MinSalary != nil {
+		query = query.Where("salary >= ?", *filter.MinSalary)
+	}
+	if filter.MaxSalary != nil {
+		query = query.Where("salary <= ?", *filter.MaxSalary)
+	}
+
+	var employees []model.Employee
+	total, err := findPage(query, list, EmployeeSortColumns, &employees)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.fillEmployees(employees, false); err != nil {
+		return nil, 0, err
+	}
+	return employees, total, nil
+}
+
+func (r *EmployeeRepository) GetByCageID(cageID uint) ([]model.Employee, error) {
+	var employees []model.Employee
+	err := r.db.Joins("JOIN employee_cages ON employee_cages.employee_id = employees.id").
+		Where("employee_cages.cage_id = ? AND employee_cages.valid_to IS NULL", cageID).
+		Find(&employees).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range employees {
+		if err := r.openPassport(&employees[i]); err != nil {
+			return nil, err
 		}
 	}
 
 	return employees, nil
 }
 
-func (r *EmployeeRepository) Update(employee *model.Employee) error {
+// GetByPassport ищет сотрудника по номеру паспорта через его хэш
+func (r *EmployeeRepository) GetByPassport(number string) (*model.Employee, error) {
+	var employee model.Employee
+	err := r.db.Where("passport_hash = ?", r.passports.Hash(number)).First(&employee).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.openPassport(&employee); err != nil {
+		return nil, err
+	}
+	return &employee, nil
+}
+
+// Update сохраняет сотрудника и приводит его закрепления к списку employee.Cages на день since:
+// периоды снятых клеток закрываются этим днем, для новых клеток открываются новые периоды.
+// Клетки, оставшиеся в списке, сохраняют свой период. exclusive - как в Create.
+func (r *EmployeeRepository) Update(employee *model.Employee, since time.Time, exclusive bool) error {
+	restore, err := r.sealPassport(employee)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Begin()
 
+	if err := lockFreeCages(tx, employee.ID, employee.Cages, exclusive); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err := tx.Save(employee).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	if err := tx.Where("employee_id = ?", employee.ID).Delete(&model.EmployeeCage{}).Error; err != nil {
+	var active []model.EmployeeCage
+	if err := tx.Where("employee_id = ? AND valid_to IS NULL", employee.ID).Find(&active).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	kept := make(map[uint]bool)
+	for _, assignment := range active {
+		if slices.Contains(employee.Cages, assignment.CageID) {
+			kept[assignment.CageID] = true
+			continue
+		}
+
+		if err := closeAssignment(tx, assignment, since); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	var added []uint
 	for _, cageID := range employee.Cages {
-		employeeCage := model.EmployeeCage{
-			EmployeeID: employee.ID,
-			CageID:     cageID,
+		if !kept[cageID] {
+			added = append(added, cageID)
+			kept[cageID] = true
 		}
-		if err := tx.Create(&employeeCage).Error; err != nil {
+	}
+
+	if err := openAssignments(tx, employee.ID, added, since); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// GetCaretakerIDs возвращает сотрудников, за которыми сейчас закреплены клетки, по ID клетки
+func (r *EmployeeRepository) GetCaretakerIDs(cageIDs []uint) (map[uint][]uint, error) {
+	var assignments []model.EmployeeCage
+	err := r.db.Where("cage_id IN ? AND valid_to IS NULL", cageIDs).Order("employee_id").Find(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	caretakers := make(map[uint][]uint)
+	for _, assignment := range assignments {
+		caretakers[assignment.CageID] = append(caretakers[assignment.CageID], assignment.EmployeeID)
+	}
+	return caretakers, nil
+}
+
+// CageMove - передача клетки от сотрудников FromEmployeeIDs сотруднику ToEmployeeID
+type CageMove struct {
+	CageID          uint
+	FromEmployeeIDs []uint
+	ToEmployeeID    uint
+}
+
+// ReassignCages в одной транзакции выполняет передачи клеток: закрепления за прежними
+// сотрудниками закрываются днем since, за новым открываются, если клетка еще не за ним.
+// При exclusive передача, после которой у клетки остался бы еще сотрудник, возвращает ErrCageTaken.
+func (r *EmployeeRepository) ReassignCages(moves []CageMove, since time.Time, exclusive bool) error {
+	tx := r.db.Begin()
+
+	cageIDs := make([]uint, len(moves))
+	for i, move := range moves {
+		cageIDs[i] = move.CageID
+	}
+	if err := lockCages(tx, cageIDs); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, move := range moves {
+		if err := reassignCage(tx, move, since, exclusive); err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -105,13 +280,158 @@ func (r *EmployeeRepository) Update(employee *model.Employee) error {
 	return tx.Commit().Error
 }
 
-func (r *EmployeeRepository) Delete(id uint) error {
+func reassignCage(tx *gorm.DB, move CageMove, since time.Time, exclusive bool) error {
+	var active []model.EmployeeCage
+	if err := tx.Where("cage_id = ? AND valid_to IS NULL", move.CageID).Find(&active).Error; err != nil {
+		return err
+	}
+
+	assigned := false
+	for _, assignment := range active {
+		if assignment.EmployeeID == move.ToEmployeeID {
+			assigned = true
+			continue
+		}
+		if !slices.Contains(move.FromEmployeeIDs, assignment.EmployeeID) {
+			if exclusive {
+				return ErrCageTaken
+			}
+			continue
+		}
+
+		if err := closeAssignment(tx, assignment, since); err != nil {
+			return err
+		}
+	}
+
+	if assigned {
+		return nil
+	}
+	return openAssignments(tx, move.ToEmployeeID, []uint{move.CageID}, since)
+}
+
+// GetCageHistory возвращает все периоды закрепления клеток за сотрудником, начиная с ранних
+func (r *EmployeeRepository) GetCageHistory(employeeID uint) ([]model.EmployeeCage, error) {
+	var assignments []model.EmployeeCage
+	err := r.db.Where("employee_id = ?", employeeID).Order("valid_from, id").Find(&assignments).Error
+	return assignments, err
+}
+
+func activeCageIDs(db *gorm.DB, employeeID uint) ([]uint, error) {
+	var assignments []model.EmployeeCage
+	err := db.Where("employee_id = ? AND valid_to IS NULL", employeeID).Order("id").Find(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	cages := make([]uint, len(assignments))
+	for i, assignment := range assignments {
+		cages[i] = assignment.CageID
+	}
+	return cages, nil
+}
+
+// activeCagesByEmployee возвращает текущие клетки сотрудников employeeIDs, nil - всех сотрудников
+func activeCagesByEmployee(db *gorm.DB, employeeIDs []uint) (map[uint][]uint, error) {
+	query := db.Where("valid_to IS NULL")
+	if employeeIDs != nil {
+		if len(employeeIDs) == 0 {
+			return map[uint][]uint{}, nil
+		}
+		query = query.Where("employee_id IN ?", employeeIDs)
+	}
+
+	var assignments []model.EmployeeCage
+	if err := query.Order("id").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+
+	cages := make(map[uint][]uint)
+	for _, assignment := range assignments {
+		cages[assignment.EmployeeID] = append(cages[assignment.EmployeeID], assignment.CageID)
+	}
+	return cages, nil
+}
+
+// lockCages блокирует строки клеток в порядке возрастания ID, чтобы встречные транзакции
+// не ждали друг друга. Закрепления меняются только под этими блокировками.
+func lockCages(tx *gorm.DB, cageIDs []uint) error {
+	sorted := slices.Clone(cageIDs)
+	slices.Sort(sorted)
+	for _, cageID := range slices.Compact(sorted) {
+		if _, err := lockCage(tx, cageID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockFreeCages блокирует клетки сотрудника employeeID и при exclusive проверяет,
+// что ни одна из них не закреплена за другим сотрудником
+func lockFreeCages(tx *gorm.DB, employeeID uint, cageIDs []uint, exclusive bool) error {
+	if len(cageIDs) == 0 {
+		return nil
+	}
+	if err := lockCages(tx, cageIDs); err != nil {
+		return err
+	}
+	if !exclusive {
+		return nil
+	}
+
+	var taken int64
+	err := tx.Model(&model.EmployeeCage{}).
+		Where("cage_id IN ? AND employee_id <> ? AND valid_to IS NULL", cageIDs, employeeID).
+		Count(&taken).Error
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrCageTaken
+	}
+	return nil
+}
+
+func openAssignments(tx *gorm.DB, employeeID uint, cageIDs []uint, since time.Time) error {
+	for _, cageID := range cageIDs {
+		assignment := model.EmployeeCage{
+			EmployeeID: employeeID,
+			CageID:     cageID,
+			ValidFrom:  since,
+		}
+		if err := tx.Create(&assignment).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeAssignment завершает период днем until. Период, который так и не начался
+// (закрепление открыто в тот же день или позже), удаляется, чтобы не оставлять пустых строк.
+func closeAssignment(tx *gorm.DB, assignment model.EmployeeCage, until time.Time) error {
+	if !assignment.ValidFrom.Before(until) {
+		return tx.Delete(&model.EmployeeCage{}, assignment.ID).Error
+	}
+
+	return tx.Model(&model.EmployeeCage{}).Where("id = ?", assignment.ID).Update("valid_to", until).Error
+}
+
+// Delete удаляет сотрудника. Его закрепления закрываются днем since, а не удаляются:
+// история нужна, чтобы яйца прошлых дней по-прежнему относились к нему.
+func (r *EmployeeRepository) Delete(id uint, since time.Time) error {
 	tx := r.db.Begin()
 
-	if err := tx.Where("employee_id = ?", id).Delete(&model.EmployeeCage{}).Error; err != nil {
+	var active []model.EmployeeCage
+	if err := tx.Where("employee_id = ? AND valid_to IS NULL", id).Find(&active).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
+	for _, assignment := range active {
+		if err := closeAssignment(tx, assignment, since); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
 
 	if err := tx.Delete(&model.Employee{}, id).Error; err != nil {
 		tx.Rollback()
@@ -126,7 +446,7 @@ func (r *EmployeeRepository) GetEmployeeChickenCount(employeeID uint) (int, erro
 
 	err := r.db.Table("employee_cages").
 		Joins("JOIN chickens ON employee_cages.cage_id = chickens.cage_id").
-		Where("employee_cages.employee_id = ?", employeeID).
+		Where("employee_cages.employee_id = ? AND employee_cages.valid_to IS NULL", employeeID).
 		Count(&count).Error
 
 	return int(count), err
@@ -142,6 +462,7 @@ func (r *EmployeeRepository) GetAllEmployeeChickenCounts() (map[uint]int, error)
 	err := r.db.Table("employee_cages").
 		Select("employee_cages.employee_id, COUNT(chickens.id) as chicken_count").
 		Joins("JOIN chickens ON employee_cages.cage_id = chickens.cage_id").
+		Where("employee_cages.valid_to IS NULL").
 		Group("employee_cages.employee_id").
 		Scan(&results).Error
 
@@ -153,13 +474,64 @@ func (r *EmployeeRepository) GetAllEmployeeChickenCounts() (map[uint]int, error)
 	return counts, err
 }
 
+// EmployeeCount - число кур или яиц сотрудника вместе с его именем
+type EmployeeCount struct {
+	EmployeeID   uint
+	EmployeeName string
+	Count        int
+}
+
+// CountChickensByEmployee считает кур в текущих клетках каждого сотрудника одним запросом;
+// сотрудники без кур возвращаются с нулем
+func (r *EmployeeRepository) CountChickensByEmployee() ([]EmployeeCount, error) {
+	var counts []EmployeeCount
+	err := r.db.Table("employees").
+		Select("employees.id AS employee_id, employees.full_name AS employee_name, COUNT(chickens.id) AS count").
+		Joins("LEFT JOIN employee_cages ON employee_cages.employee_id = employees.id AND employee_cages.valid_to IS NULL").
+		Joins("LEFT JOIN chickens ON chickens.cage_id = employee_cages.cage_id").
+		Group("employees.id, employees.full_name").
+		Order("employees.id").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// CountEggsByEmployee считает яйца каждого сотрудника с startDate по endDate включительно одним запросом;
+// яйцо засчитывается тому, за кем клетка была закреплена в день записи
+func (r *EmployeeRepository) CountEggsByEmployee(startDate, endDate string) ([]EmployeeCount, error) {
+	start, end, err := dayRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []EmployeeCount
+	err = r.db.Table("employees").
+		Select("employees.id AS employee_id, employees.full_name AS employee_name, COUNT(farm_records.id) AS count").
+		Joins("LEFT JOIN employee_cages ON employee_cages.employee_id = employees.id").
+		Joins("LEFT JOIN farm_records ON "+assignmentOnRecordDate+
+			" AND farm_records.date >= ? AND farm_records.date < ? AND farm_records.has_egg = ?", start, end, true).
+		Group("employees.id, employees.full_name").
+		Order("employees.id").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// assignmentOnRecordDate связывает запись о яйце с закреплением клетки, действовавшим в день записи
+const assignmentOnRecordDate = "employee_cages.cage_id = farm_records.cage_id" +
+	" AND farm_records.date >= employee_cages.valid_from" +
+	" AND (employee_cages.valid_to IS NULL OR farm_records.date < employee_cages.valid_to)"
+
 func (r *EmployeeRepository) GetEmployeeEggCount(employeeID uint, startDate, endDate string) (int, error) {
+	start, end, err := dayRange(startDate, endDate)
+	if err != nil {
+		return 0, err
+	}
+
 	var count int64
 
-	err := r.db.Table("employee_cages").
-		Joins("JOIN farm_records ON employee_cages.cage_id = farm_records.cage_id").
-		Where("employee_cages.employee_id = ? AND farm_records.date BETWEEN ? AND ? AND farm_records.has_egg = true",
-			employeeID, startDate, endDate).
+	err = r.db.Table("employee_cages").
+		Joins("JOIN farm_records ON "+assignmentOnRecordDate).
+		Where("employee_cages.employee_id = ? AND farm_records.date >= ? AND farm_records.date < ? AND farm_records.has_egg = ?",
+			employeeID, start, end, true).
 		Count(&count).Error
 
 	return int(count), err
@@ -171,11 +543,16 @@ func (r *EmployeeRepository) GetAllEmployeeEggCounts(startDate, endDate string)
 		EggCount   int64
 	}
 
+	start, end, err := dayRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
 	var results []Result
-	err := r.db.Table("employee_cages").
+	err = r.db.Table("employee_cages").
 		Select("employee_cages.employee_id, COUNT(farm_records.id) as egg_count").
-		Joins("JOIN farm_records ON employee_cages.cage_id = farm_records.cage_id").
-		Where("farm_records.date BETWEEN ? AND ? AND farm_records.has_egg = true", startDate, endDate).
+		Joins("JOIN farm_records ON "+assignmentOnRecordDate).
+		Where("farm_records.date >= ? AND farm_records.date < ? AND farm_records.has_egg = ?", start, end, true).
 		Group("employee_cages.employee_id").
 		Scan(&results).Error
 
@@ -186,3 +563,81 @@ func (r *EmployeeRepository) GetAllEmployeeEggCounts(startDate, endDate string)
 
 	return counts, err
 }
+
+// CountUnencryptedPassports считает сотрудников, сохраненных до появления шифрования
+func (r *EmployeeRepository) CountUnencryptedPassports() (int, error) {
+	var count int64
+	err := r.db.Model(&model.Employee{}).Where("passport_hash IS NULL").Count(&count).Error
+	return int(count), err
+}
+
+// EncryptPassports шифрует паспорта, сохраненные открытым текстом, и заполняет их хэш.
+// Все строки обновляются в одной транзакции; возвращает число обновленных сотрудников.
+func (r *EmployeeRepository) EncryptPassports() (int, error) {
+	tx := r.db.Begin()
+
+	var employees []model.Employee
+	if err := tx.Where("passport_hash IS NULL").Find(&employees).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	for _, employee := range employees {
+		number, err := r.passports.Decrypt(employee.PassportData)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		encrypted, err := r.passports.Encrypt(number)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		err = tx.Model(&model.Employee{}).Where("id = ?", employee.ID).Updates(map[string]interface{}{
+			"passport_data": encrypted,
+			"passport_hash": r.passports.Hash(number),
+		}).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			tx.Rollback()
+			return 0, fmt.Errorf("employee %d has the same passport as another employee: %w", employee.ID, err)
+		} else if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+
+	return len(employees), nil
+}
+
+// sealPassport заменяет номер паспорта зашифрованным значением и хэшем перед записью.
+// Возвращаемая функция кладет открытый номер обратно.
+func (r *EmployeeRepository) sealPassport(employee *model.Employee) (func(), error) {
+	number := employee.PassportData
+
+	encrypted, err := r.passports.Encrypt(number)
+	if err != nil {
+		return nil, err
+	}
+	hash := r.passports.Hash(number)
+
+	employee.PassportData = encrypted
+	employee.PassportHash = &hash
+
+	return func() { employee.PassportData = number }, nil
+}
+
+func (r *EmployeeRepository) openPassport(employee *model.Employee) error {
+	number, err := r.passports.Decrypt(employee.PassportData)
+	if err != nil {
+		return err
+	}
+
+	employee.PassportData = number
+	return nil
+}
@@ -1,11 +1,20 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
 	"chicken-farm/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// формат дат в параметрах отчетов
+const dateLayout = "2006-01-02"
+
 type FarmRepository struct {
 	db *gorm.DB
 }
@@ -28,9 +37,30 @@ func (r *FarmRepository) GetByID(id uint) (*model.Farm, error) {
 	return &farm, nil
 }
 
+// dayRange переводит границы периода в формате YYYY-MM-DD в полуинтервал [start, end+1 день):
+// последний день входит в период, а даты сравниваются как время, а не как строки
+func dayRange(startDate, endDate string) (time.Time, time.Time, error) {
+	start, err := time.Parse(dateLayout, startDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q: %w", startDate, err)
+	}
+
+	end, err := time.Parse(dateLayout, endDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q: %w", endDate, err)
+	}
+
+	return start, end.AddDate(0, 0, 1), nil
+}
+
 func (r *FarmRepository) GetByDateRange(startDate, endDate string) ([]model.Farm, error) {
+	start, end, err := dayRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
 	var farms []model.Farm
-	err := r.db.Where("date BETWEEN ? AND ?", startDate, endDate).Find(&farms).Error
+	err = r.db.Where("date >= ? AND date < ?", start, end).Find(&farms).Error
 	return farms, err
 }
 
@@ -40,22 +70,354 @@ func (r *FarmRepository) GetByChickenID(chickenID uint) ([]model.Farm, error) {
 	return farms, err
 }
 
+// GetEggCountsByChicken возвращает число снесенных яиц каждой курицей в полуинтервале [start, end)
+func (r *FarmRepository) GetEggCountsByChicken(start, end time.Time) (map[uint]int, error) {
+	type Result struct {
+		ChickenID uint
+		EggCount  int64
+	}
+
+	var results []Result
+	err := r.db.Model(&model.Farm{}).
+		Select("chicken_id, COUNT(*) as egg_count").
+		Where("has_egg = ? AND date >= ? AND date < ?", true, start, end).
+		Group("chicken_id").
+		Scan(&results).Error
+
+	counts := make(map[uint]int)
+	for _, r := range results {
+		counts[r.ChickenID] = int(r.EggCount)
+	}
+
+	return counts, err
+}
+
+type FarmRecordFilter struct {
+	StartDate *time.Time
+	EndDate   *time.Time
+	CageID    uint
+	ChickenID uint
+}
+
+func (r *FarmRepository) Find(filter FarmRecordFilter) ([]model.Farm, error) {
+	query := r.db.Model(&model.Farm{})
+
+	if filter.StartDate != nil {
+		query = query.Where("date >= ?", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		// конец периода включительно
+		query = query.Where("date < ?", filter.EndDate.AddDate(0, 0, 1))
+	}
+	if filter.CageID != 0 {
+		query = query.Where("cage_id = ?", filter.CageID)
+	}
+	if filter.ChickenID != 0 {
+		query = query.Where("chicken_id = ?", filter.ChickenID)
+	}
+
+	var farms []model.Farm
+	err := query.Order("date, cage_id").Find(&farms).Error
+	return farms, err
+}
+
+func (r *FarmRepository) GetByChickenAndDate(chickenID uint, date time.Time) (*model.Farm, error) {
+	var farm model.Farm
+	err := r.db.Where("chicken_id = ? AND date = ?", chickenID, date).First(&farm).Error
+	if err != nil {
+		return nil, err
+	}
+	return &farm, nil
+}
+
+func (r *FarmRepository) GetByDate(date time.Time) ([]model.Farm, error) {
+	var farms []model.Farm
+	err := r.db.Where("date = ?", date).Find(&farms).Error
+	return farms, err
+}
+
+// SaveDay создает или обновляет записи за один день в одной транзакции
+func (r *FarmRepository) SaveDay(date time.Time, farms []model.Farm) error {
+	tx := r.db.Begin()
+
+	for i := range farms {
+		farms[i].Date = date
+
+		var existing model.Farm
+		err := tx.Where("chicken_id = ? AND date = ?", farms[i].ChickenID, date).First(&existing).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			tx.Rollback()
+			return err
+		}
+
+		if err == nil {
+			farms[i].ID = existing.ID
+			farms[i].CreatedAt = existing.CreatedAt
+		}
+
+		if err := tx.Save(&farms[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+func (r *FarmRepository) Update(farm *model.Farm) error {
+	return r.db.Save(farm).Error
+}
+
+func (r *FarmRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Farm{}, id).Error
+}
+
 func (r *FarmRepository) GetEggCountByDateRange(startDate, endDate string) (int, error) {
+	start, end, err := dayRange(startDate, endDate)
+	if err != nil {
+		return 0, err
+	}
+
 	var count int64
-	err := r.db.Model(&model.Farm{}).
-		Where("date BETWEEN ? AND ? AND has_egg = true", startDate, endDate).
+	err = r.db.Model(&model.Farm{}).
+		Where("date >= ? AND date < ? AND has_egg = ?", start, end, true).
 		Count(&count).Error
 
 	return int(count), err
 }
 
 func (r *FarmRepository) GetTotalEggCost(startDate, endDate string) (float64, error) {
-	eggCount, err := r.GetEggCountByDateRange(startDate, endDate)
+	periods, err := r.GetEggCostByPricePeriod(startDate, endDate)
 	if err != nil {
 		return 0, err
 	}
 
-	return float64(eggCount) * model.GetEggPrice(), nil
+	var total float64
+	for _, period := range periods {
+		total += period.Cost
+	}
+
+	return total, nil
+}
+
+// EggPricePeriod - яйца, собранные за время действия одной цены
+type EggPricePeriod struct {
+	EffectiveFrom *time.Time `json:"effective_from"` // nil - базовая цена до начала истории цен
+	EffectiveTo   *time.Time `json:"effective_to"`   // nil - цена действует до сих пор
+	Price         float64    `json:"price"`
+	EggCount      int        `json:"egg_count"`
+	Cost          float64    `json:"cost"`
+}
+
+// GetEggCostByPricePeriod считает стоимость яиц по цене, действовавшей в день каждой записи
+func (r *FarmRepository) GetEggCostByPricePeriod(startDate, endDate string) ([]EggPricePeriod, error) {
+	type Result struct {
+		Date     time.Time
+		EggCount int64
+	}
+
+	start, end, err := dayRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	err = r.db.Model(&model.Farm{}).
+		Select("date, COUNT(*) as egg_count").
+		Where("date >= ? AND date < ? AND has_egg = ?", start, end, true).
+		Group("date").
+		Order("date").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	prices, err := r.GetEggPrices()
+	if err != nil {
+		return nil, err
+	}
+
+	basePrice, err := baseEggPrice(r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	periods := make([]EggPricePeriod, 0)
+	current := -2
+	for _, result := range results {
+		index := priceIndexAt(prices, result.Date)
+		if index != current {
+			periods = append(periods, newEggPricePeriod(prices, index, basePrice))
+			current = index
+		}
+
+		period := &periods[len(periods)-1]
+		period.EggCount += int(result.EggCount)
+		period.Cost += float64(result.EggCount) * period.Price
+	}
+
+	return periods, nil
+}
+
+// GetEggPrice возвращает цену яйца, действующую сегодня
+func (r *FarmRepository) GetEggPrice() (float64, error) {
+	prices, err := r.GetEggPrices()
+	if err != nil {
+		return 0, err
+	}
+
+	if index := priceIndexAt(prices, time.Now()); index >= 0 {
+		return prices[index].Price, nil
+	}
+
+	return baseEggPrice(r.db)
+}
+
+// baseEggPrice возвращает цену из config_params, она действует до первой записи в истории цен
+func baseEggPrice(db *gorm.DB) (float64, error) {
+	var param model.ConfigParam
+	err := db.Where(map[string]interface{}{"key": model.ConfigEggPrice}).First(&param).Error
+	if err == gorm.ErrRecordNotFound {
+		return model.DefaultEggPrice, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(param.Value, 64)
+}
+
+func (r *FarmRepository) GetEggPrices() ([]model.EggPrice, error) {
+	var prices []model.EggPrice
+	err := r.db.Order("effective_from").Find(&prices).Error
+	return prices, err
+}
+
+func (r *FarmRepository) GetEggPriceByID(id uint) (*model.EggPrice, error) {
+	var price model.EggPrice
+	err := r.db.First(&price, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// SaveEggPrice добавляет цену в историю или заменяет цену, действующую с той же даты.
+// Если истории цен еще нет, прежняя цена из config_params сохраняется в нее как начальная,
+// чтобы не поменялась стоимость уже собранных яиц. egg_price в config_params после этого
+// всегда совпадает с ценой, действующей сегодня.
+func (r *FarmRepository) SaveEggPrice(price *model.EggPrice) error {
+	tx := r.db.Begin()
+
+	var count int64
+	if err := tx.Model(&model.EggPrice{}).Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	initialDate := time.Unix(0, 0).UTC()
+	if count == 0 && price.EffectiveFrom.After(initialDate) {
+		basePrice, err := baseEggPrice(tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		initial := model.EggPrice{Price: basePrice, EffectiveFrom: initialDate}
+		if err := saveEggPrice(tx, &initial); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := saveEggPrice(tx, price); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := syncEggPriceParam(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (r *FarmRepository) DeleteEggPrice(id uint) error {
+	tx := r.db.Begin()
+
+	if err := tx.Delete(&model.EggPrice{}, id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := syncEggPriceParam(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func saveEggPrice(tx *gorm.DB, price *model.EggPrice) error {
+	var existing model.EggPrice
+	err := tx.Where("effective_from = ?", price.EffectiveFrom).First(&existing).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	if err == nil {
+		price.ID = existing.ID
+		price.CreatedAt = existing.CreatedAt
+	}
+
+	return tx.Save(price).Error
+}
+
+func syncEggPriceParam(tx *gorm.DB) error {
+	var prices []model.EggPrice
+	if err := tx.Order("effective_from").Find(&prices).Error; err != nil {
+		return err
+	}
+
+	index := priceIndexAt(prices, time.Now())
+	if index < 0 {
+		return nil
+	}
+
+	return updateConfigParam(tx, model.ConfigEggPrice, strconv.FormatFloat(prices[index].Price, 'f', -1, 64))
+}
+
+// priceIndexAt возвращает индекс цены, действовавшей на дату, или -1, если история начинается позже
+func priceIndexAt(prices []model.EggPrice, date time.Time) int {
+	index := -1
+	for i, price := range prices {
+		if price.EffectiveFrom.After(date) {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+func newEggPricePeriod(prices []model.EggPrice, index int, basePrice float64) EggPricePeriod {
+	if index < 0 {
+		period := EggPricePeriod{Price: basePrice}
+		if len(prices) > 0 {
+			effectiveTo := prices[0].EffectiveFrom.AddDate(0, 0, -1)
+			period.EffectiveTo = &effectiveTo
+		}
+		return period
+	}
+
+	period := EggPricePeriod{
+		EffectiveFrom: &prices[index].EffectiveFrom,
+		Price:         prices[index].Price,
+	}
+	if index+1 < len(prices) {
+		effectiveTo := prices[index+1].EffectiveFrom.AddDate(0, 0, -1)
+		period.EffectiveTo = &effectiveTo
+	}
+	return period
 }
 
 func (r *FarmRepository) GetCageWithMostEggs() (uint, error) {
@@ -64,15 +426,23 @@ func (r *FarmRepository) GetCageWithMostEggs() (uint, error) {
 		EggCount int64
 	}
 
-	var result Result
+	// First добавил бы сортировку по id, которую PostgreSQL не допускает вместе с GROUP BY
+	var results []Result
 	err := r.db.Model(&model.Farm{}).
 		Select("cage_id, COUNT(*) as egg_count").
-		Where("has_egg = true").
+		Where("has_egg = ?", true).
 		Group("cage_id").
-		Order("egg_count DESC").
-		First(&result).Error
+		Order("egg_count DESC, cage_id").
+		Limit(1).
+		Scan(&results).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
 
-	return result.CageID, err
+	return results[0].CageID, nil
 }
 
 func (r *FarmRepository) CreateCage(cage *model.Cage) error {
@@ -88,70 +458,184 @@ func (r *FarmRepository) GetCageByID(id uint) (*model.Cage, error) {
 	return &cage, nil
 }
 
+func (r *FarmRepository) GetCageByNumber(number int) (*model.Cage, error) {
+	var cage model.Cage
+	err := r.db.Where("number = ?", number).First(&cage).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cage, nil
+}
+
 func (r *FarmRepository) GetAllCages() ([]model.Cage, error) {
 	var cages []model.Cage
-	err := r.db.Find(&cages).Error
+	err := r.db.Order("number").Find(&cages).Error
 	return cages, err
 }
 
-func (r *FarmRepository) GetEmptyCages() ([]model.Cage, error) {
-	var allCageIDs []uint
-	var occupiedCageIDs []uint
+// GetCageOccupancy возвращает количество кур в каждой занятой клетке
+func (r *FarmRepository) GetCageOccupancy() (map[uint]int, error) {
+	type Result struct {
+		CageID       uint
+		ChickenCount int64
+	}
 
-	if err := r.db.Model(&model.Cage{}).Pluck("id", &allCageIDs).Error; err != nil {
-		return nil, err
+	var results []Result
+	err := r.db.Model(&model.Chicken{}).
+		Select("cage_id, COUNT(*) as chicken_count").
+		Group("cage_id").
+		Scan(&results).Error
+
+	occupancy := make(map[uint]int)
+	for _, r := range results {
+		occupancy[r.CageID] = int(r.ChickenCount)
 	}
 
-	if err := r.db.Model(&model.Chicken{}).Pluck("cage_id", &occupiedCageIDs).Error; err != nil {
-		return nil, err
+	return occupancy, err
+}
+
+// UpdateCage сохраняет клетку под блокировкой ее строки. Если кур в клетке больше
+// новой вместимости, возвращает ErrCapacityBelowOccupancy.
+func (r *FarmRepository) UpdateCage(cage *model.Cage) error {
+	tx := r.db.Begin()
+
+	if _, err := lockCage(tx, cage.ID); err != nil {
+		tx.Rollback()
+		return err
 	}
 
-	emptyCageIDs := make([]uint, 0)
-	for _, cageID := range allCageIDs {
-		found := false
-		for _, occupiedID := range occupiedCageIDs {
-			if cageID == occupiedID {
-				found = true
-				break
-			}
-		}
-		if !found {
-			emptyCageIDs = append(emptyCageIDs, cageID)
-		}
+	var occupancy int64
+	if err := tx.Model(&model.Chicken{}).Where("cage_id = ?", cage.ID).Count(&occupancy).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if occupancy > int64(cage.Capacity) {
+		tx.Rollback()
+		return ErrCapacityBelowOccupancy
+	}
+
+	if err := tx.Save(cage).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// ErrCageHasChickens и ErrCageHasEmployees - клетку нельзя удалить, пока в ней живут куры
+// или за ней закреплен сотрудник
+var (
+	ErrCageHasChickens  = errors.New("cage is occupied by a chicken")
+	ErrCageHasEmployees = errors.New("cage is assigned to employees")
+)
+
+// DeleteCage удаляет пустую клетку без сотрудников. Проверка и удаление идут под блокировкой клетки,
+// чтобы курицу или сотрудника не успели поселить или закрепить между ними.
+func (r *FarmRepository) DeleteCage(id uint) error {
+	tx := r.db.Begin()
+
+	if _, err := lockCage(tx, id); err != nil {
+		tx.Rollback()
+		return err
 	}
 
-	var emptyCages []model.Cage
-	if len(emptyCageIDs) > 0 {
-		err := r.db.Where("id IN ?", emptyCageIDs).Find(&emptyCages).Error
-		return emptyCages, err
+	var occupancy int64
+	if err := tx.Model(&model.Chicken{}).Where("cage_id = ?", id).Count(&occupancy).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if occupancy > 0 {
+		tx.Rollback()
+		return ErrCageHasChickens
 	}
 
-	return emptyCages, nil
+	var assignments int64
+	err := tx.Model(&model.EmployeeCage{}).Where("cage_id = ? AND valid_to IS NULL", id).Count(&assignments).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if assignments > 0 {
+		tx.Rollback()
+		return ErrCageHasEmployees
+	}
+
+	if err := tx.Delete(&model.Cage{}, id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// GetEmptyCages возвращает клетки, в которых нет ни одной курицы
+func (r *FarmRepository) GetEmptyCages() ([]model.Cage, error) {
+	var cages []model.Cage
+	err := r.db.Where("NOT EXISTS (?)",
+		r.db.Table("chickens").Select("1").Where("chickens.cage_id = cages.id")).
+		Order("id").
+		Find(&cages).Error
+	return cages, err
+}
+
+// GetUnattendedCages возвращает клетки, за которыми сейчас не закреплен ни один сотрудник
+func (r *FarmRepository) GetUnattendedCages() ([]model.Cage, error) {
+	var cages []model.Cage
+	err := r.db.Where("NOT EXISTS (?)",
+		r.db.Table("employee_cages").Select("1").
+			Where("employee_cages.cage_id = cages.id AND employee_cages.valid_to IS NULL")).
+		Order("number").
+		Find(&cages).Error
+	return cages, err
+}
+
+// GetSharedCages возвращает клетки, за которыми сейчас закреплено несколько сотрудников
+func (r *FarmRepository) GetSharedCages() ([]model.Cage, error) {
+	var cages []model.Cage
+	err := r.db.Where("id IN (?)",
+		r.db.Table("employee_cages").Select("cage_id").
+			Where("valid_to IS NULL").
+			Group("cage_id").
+			Having("COUNT(DISTINCT employee_id) > 1")).
+		Order("number").
+		Find(&cages).Error
+	return cages, err
 }
 
 func (r *FarmRepository) UpdateConfigParam(key, value string) error {
+	return updateConfigParam(r.db, key, value)
+}
+
+func updateConfigParam(db *gorm.DB, key, value string) error {
 	var param model.ConfigParam
 
-	err := r.db.Where("key = ?", key).First(&param).Error
+	err := db.Where(map[string]interface{}{"key": key}).First(&param).Error
 	if err == gorm.ErrRecordNotFound {
 		param = model.ConfigParam{
 			Key:   key,
 			Value: value,
 		}
-		return r.db.Create(&param).Error
+		return db.Create(&param).Error
 	} else if err != nil {
 		return err
 	}
 
 	param.Value = value
-	return r.db.Save(&param).Error
+	return db.Save(&param).Error
 }
 
 func (r *FarmRepository) GetConfigParam(key string) (string, error) {
 	var param model.ConfigParam
-	err := r.db.Where("key = ?", key).First(&param).Error
+	err := r.db.Where(map[string]interface{}{"key": key}).First(&param).Error
 	if err != nil {
 		return "", err
 	}
 	return param.Value, nil
 }
+
+func (r *FarmRepository) GetAllConfigParams() ([]model.ConfigParam, error) {
+	var params []model.ConfigParam
+	// key - зарезервированное слово, имя столбца экранирует диалект
+	err := r.db.Order(clause.OrderByColumn{Column: clause.Column{Name: "key"}}).Find(&params).Error
+	return params, err
+}
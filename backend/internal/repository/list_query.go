@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+)
+
+// ListQuery - страница списка и порядок строк в ней
+type ListQuery struct {
+	Page    int // номер страницы с 1
+	PerPage int // 0 - весь список одной страницей
+	Sort    []SortField
+}
+
+// SortField - поле сортировки; Field - ключ из ChickenSortColumns или EmployeeSortColumns
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ChickenSortColumns и EmployeeSortColumns перечисляют поля, по которым можно сортировать списки
+var (
+	ChickenSortColumns = map[string]string{
+		"id":            "id",
+		"cage_id":       "cage_id",
+		"weight":        "weight",
+		"hatch_date":    "hatch_date",
+		"egg_per_month": "egg_per_month",
+		"breed":         "breed",
+		"created_at":    "created_at",
+	}
+	EmployeeSortColumns = map[string]string{
+		"id":           "id",
+		"full_name":    "full_name",
+		"salary":       "salary",
+		"max_chickens": "max_chickens",
+		"created_at":   "created_at",
+	}
+)
+
+// paginate упорядочивает запрос по полям list.Sort и вырезает страницу.
+// Последним ключом всегда идет id, чтобы строки с равными значениями не переходили между страницами.
+func paginate(query *gorm.DB, list ListQuery, columns map[string]string) *gorm.DB {
+	byID := false
+	for _, field := range list.Sort {
+		column, ok := columns[field.Field]
+		if !ok {
+			continue
+		}
+		if field.Desc {
+			column += " DESC"
+		}
+		query = query.Order(column)
+		byID = byID || field.Field == "id"
+	}
+	if !byID {
+		query = query.Order("id")
+	}
+
+	if list.PerPage == 0 {
+		return query
+	}
+	return query.Offset((list.Page - 1) * list.PerPage).Limit(list.PerPage)
+}
+
+// findPage считает все строки, подходящие под query, и загружает в dest одну страницу
+func findPage(query *gorm.DB, list ListQuery, columns map[string]string, dest interface{}) (int64, error) {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	err := paginate(query.Session(&gorm.Session{}), list, columns).Find(dest).Error
+	return total, err
+}
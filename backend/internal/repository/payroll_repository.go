@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"time"
+
+	"chicken-farm/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type PayrollRepository struct {
+	db *gorm.DB
+}
+
+func NewPayrollRepository(db *gorm.DB) *PayrollRepository {
+	return &PayrollRepository{db: db}
+}
+
+// GetPayslips возвращает расчетные листки за месяц вместе со строками
+func (r *PayrollRepository) GetPayslips(period string) ([]model.Payslip, error) {
+	var payslips []model.Payslip
+	err := r.db.Where("period = ?", period).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("employee_id").
+		Find(&payslips).Error
+	return payslips, err
+}
+
+// ReplaceDrafts заменяет черновики листков за месяц новыми в одной транзакции.
+// Утвержденные листки не трогаются: если они есть, возвращается false и ничего не меняется.
+func (r *PayrollRepository) ReplaceDrafts(period string, payslips []model.Payslip) (bool, error) {
+	tx := r.db.Begin()
+
+	var finalized int64
+	err := tx.Model(&model.Payslip{}).
+		Where("period = ? AND status = ?", period, model.PayslipFinalized).
+		Count(&finalized).Error
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if finalized > 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	drafts := tx.Model(&model.Payslip{}).Select("id").Where("period = ?", period)
+	if err := tx.Where("payslip_id IN (?)", drafts).Delete(&model.PayslipLine{}).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err := tx.Where("period = ?", period).Delete(&model.Payslip{}).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	for i := range payslips {
+		if err := tx.Create(&payslips[i]).Error; err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	return true, tx.Commit().Error
+}
+
+// Finalize утверждает черновики листков за месяц и возвращает число утвержденных
+func (r *PayrollRepository) Finalize(period string, at time.Time) (int, error) {
+	result := r.db.Model(&model.Payslip{}).
+		Where("period = ? AND status = ?", period, model.PayslipDraft).
+		Updates(map[string]interface{}{"status": model.PayslipFinalized, "finalized_at": at})
+	return int(result.RowsAffected), result.Error
+}
+
+// IsFinalized сообщает, есть ли за месяц утвержденные листки
+func (r *PayrollRepository) IsFinalized(period string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Payslip{}).
+		Where("period = ? AND status = ?", period, model.PayslipFinalized).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *PayrollRepository) CreateAbsence(absence *model.Absence) error {
+	return r.db.Create(absence).Error
+}
+
+func (r *PayrollRepository) GetAbsenceByID(id uint) (*model.Absence, error) {
+	var absence model.Absence
+	err := r.db.First(&absence, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &absence, nil
+}
+
+func (r *PayrollRepository) GetAbsenceByEmployeeAndDate(employeeID uint, date time.Time) (*model.Absence, error) {
+	var absence model.Absence
+	err := r.db.Where("employee_id = ? AND date = ?", employeeID, date).First(&absence).Error
+	if err != nil {
+		return nil, err
+	}
+	return &absence, nil
+}
+
+// GetAbsences возвращает пропуски в полуинтервале [start, end)
+func (r *PayrollRepository) GetAbsences(start, end time.Time) ([]model.Absence, error) {
+	var absences []model.Absence
+	err := r.db.Where("date >= ? AND date < ?", start, end).Order("date, employee_id").Find(&absences).Error
+	return absences, err
+}
+
+func (r *PayrollRepository) DeleteAbsence(id uint) error {
+	return r.db.Delete(&model.Absence{}, id).Error
+}
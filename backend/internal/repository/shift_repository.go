@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"time"
+
+	"chicken-farm/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type ShiftRepository struct {
+	db *gorm.DB
+}
+
+func NewShiftRepository(db *gorm.DB) *ShiftRepository {
+	return &ShiftRepository{db: db}
+}
+
+type ShiftFilter struct {
+	EmployeeID uint
+	Start      time.Time // смены, начинающиеся в полуинтервале [Start, End)
+	End        time.Time
+}
+
+func (r *ShiftRepository) CreateShift(shift *model.Shift) error {
+	return r.db.Create(shift).Error
+}
+
+func (r *ShiftRepository) GetShiftByID(id uint) (*model.Shift, error) {
+	var shift model.Shift
+	err := r.db.First(&shift, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+func (r *ShiftRepository) FindShifts(filter ShiftFilter) ([]model.Shift, error) {
+	query := r.db.Where("starts_at >= ? AND starts_at < ?", filter.Start, filter.End)
+	if filter.EmployeeID != 0 {
+		query = query.Where("employee_id = ?", filter.EmployeeID)
+	}
+
+	var shifts []model.Shift
+	err := query.Order("starts_at, employee_id").Find(&shifts).Error
+	return shifts, err
+}
+
+// HasOverlappingShift сообщает, пересекается ли интервал [startsAt, endsAt) с другой сменой сотрудника
+func (r *ShiftRepository) HasOverlappingShift(employeeID uint, startsAt, endsAt time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Shift{}).
+		Where("employee_id = ? AND starts_at < ? AND ends_at > ?", employeeID, endsAt, startsAt).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FindShiftAt ищет смену сотрудника, на которую можно отметиться в момент at:
+// смена еще не закончилась и начнется не позже чем через early
+func (r *ShiftRepository) FindShiftAt(employeeID uint, at time.Time, early time.Duration) (*model.Shift, error) {
+	var shift model.Shift
+	err := r.db.Where("employee_id = ? AND starts_at <= ? AND ends_at > ?", employeeID, at.Add(early), at).
+		Order("starts_at").
+		First(&shift).Error
+	if err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+func (r *ShiftRepository) DeleteShift(id uint) error {
+	return r.db.Delete(&model.Shift{}, id).Error
+}
+
+func (r *ShiftRepository) CountTimeEntriesForShift(shiftID uint) (int, error) {
+	var count int64
+	err := r.db.Model(&model.TimeEntry{}).Where("shift_id = ?", shiftID).Count(&count).Error
+	return int(count), err
+}
+
+func (r *ShiftRepository) CreateTimeEntry(entry *model.TimeEntry) error {
+	return r.db.Create(entry).Error
+}
+
+// GetOpenTimeEntry возвращает отметку о приходе, после которой сотрудник еще не ушел
+func (r *ShiftRepository) GetOpenTimeEntry(employeeID uint) (*model.TimeEntry, error) {
+	var entry model.TimeEntry
+	err := r.db.Where("employee_id = ? AND clock_out_at IS NULL", employeeID).First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// HasTimeEntryBetween сообщает, был ли сотрудник на ферме хотя бы часть полуинтервала [start, end)
+func (r *ShiftRepository) HasTimeEntryBetween(employeeID uint, start, end time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.TimeEntry{}).
+		Where("employee_id = ? AND clock_in_at < ? AND (clock_out_at IS NULL OR clock_out_at > ?)", employeeID, end, start).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *ShiftRepository) UpdateTimeEntry(entry *model.TimeEntry) error {
+	return r.db.Save(entry).Error
+}
+
+// GetTimeEntries возвращает отметки с приходом в полуинтервале [start, end)
+func (r *ShiftRepository) GetTimeEntries(start, end time.Time) ([]model.TimeEntry, error) {
+	var entries []model.TimeEntry
+	err := r.db.Where("clock_in_at >= ? AND clock_in_at < ?", start, end).
+		Order("clock_in_at, employee_id").
+		Find(&entries).Error
+	return entries, err
+}
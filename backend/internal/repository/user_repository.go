@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"time"
+
+	"chicken-farm/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Create(user *model.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *UserRepository) GetByID(id uint) (*model.User, error) {
+	var user model.User
+	err := r.db.First(&user, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
+	var user model.User
+	err := r.db.Where("username = ?", username).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) GetAll() ([]model.User, error) {
+	var users []model.User
+	err := r.db.Order("username").Find(&users).Error
+	return users, err
+}
+
+func (r *UserRepository) Count() (int, error) {
+	var count int64
+	err := r.db.Model(&model.User{}).Count(&count).Error
+	return int(count), err
+}
+
+// Update сохраняет пользователя; при revokeSessions в той же транзакции удаляет все его сессии,
+// чтобы после смены пароля старые токены перестали работать
+func (r *UserRepository) Update(user *model.User, revokeSessions bool) error {
+	tx := r.db.Begin()
+
+	if err := tx.Save(user).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if revokeSessions {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&model.Session{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Delete удаляет пользователя вместе с его сессиями
+func (r *UserRepository) Delete(id uint) error {
+	tx := r.db.Begin()
+
+	if err := tx.Where("user_id = ?", id).Delete(&model.Session{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Delete(&model.User{}, id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (r *UserRepository) CreateSession(session *model.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *UserRepository) GetSessionByTokenHash(tokenHash string) (*model.Session, error) {
+	var session model.Session
+	err := r.db.Where("token_hash = ?", tokenHash).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *UserRepository) DeleteSession(id uint) error {
+	return r.db.Delete(&model.Session{}, id).Error
+}
+
+// DeleteExpiredSessions удаляет истекшие сессии пользователя
+func (r *UserRepository) DeleteExpiredSessions(userID uint, now time.Time) error {
+	return r.db.Where("user_id = ? AND expires_at < ?", userID, now).Delete(&model.Session{}).Error
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type AuthService struct {
+	userRepo     *repository.UserRepository
+	employeeRepo *repository.EmployeeRepository
+	sessionTTL   time.Duration
+}
+
+func NewAuthService(
+	userRepo *repository.UserRepository,
+	employeeRepo *repository.EmployeeRepository,
+	sessionTTL time.Duration,
+) *AuthService {
+	return &AuthService{
+		userRepo:     userRepo,
+		employeeRepo: employeeRepo,
+		sessionTTL:   sessionTTL,
+	}
+}
+
+// LoginResult - токен новой сессии. Сам токен нигде не хранится, клиент передает его
+// в заголовке Authorization: Bearer <token>.
+type LoginResult struct {
+	Token     string      `json:"token"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	User      *model.User `json:"user"`
+}
+
+// ErrNoAdminPassword - база пуста, а пароль первого администратора не задан
+var ErrNoAdminPassword = errors.New("the database has no users: set auth.admin_password or " +
+	"CHICKEN_FARM_ADMIN_PASSWORD to create the first admin")
+
+// хэш для сравнения, когда пользователь не найден: ответ занимает столько же времени,
+// сколько и при неверном пароле, и не выдает, существует ли такой логин
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("chicken-farm"), bcrypt.DefaultCost)
+
+func (s *AuthService) Login(username, password string) (*LoginResult, error) {
+	user, err := s.userRepo.GetByUsername(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.userRepo.DeleteExpiredSessions(user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s.StartSession(user)
+}
+
+// StartSession выдает пользователю новый токен без проверки пароля
+func (s *AuthService) StartSession(user *model.User) (*LoginResult, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	session := &model.Session{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.sessionTTL),
+	}
+	if err := s.userRepo.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+		User:      user,
+	}, nil
+}
+
+// Authenticate возвращает владельца действующей сессии
+func (s *AuthService) Authenticate(token string) (*model.User, error) {
+	session, err := s.userRepo.GetSessionByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, notFoundOr(err, ErrInvalidToken)
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		return nil, notFoundOr(err, ErrInvalidToken)
+	}
+
+	return user, nil
+}
+
+func (s *AuthService) Logout(token string) error {
+	session, err := s.userRepo.GetSessionByTokenHash(hashToken(token))
+	if err != nil {
+		return notFoundOr(err, ErrInvalidToken)
+	}
+
+	return s.userRepo.DeleteSession(session.ID)
+}
+
+// EnsureAdmin создает администратора, если в базе еще нет ни одного пользователя.
+// Без пароля пустую базу оставлять нельзя: войти в нее будет некому.
+func (s *AuthService) EnsureAdmin(username, password string) (bool, error) {
+	count, err := s.userRepo.Count()
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	if password == "" {
+		return false, ErrNoAdminPassword
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return false, err
+	}
+
+	admin := &model.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         model.RoleAdmin,
+	}
+	if err := s.userRepo.Create(admin); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CheckCageAccess проверяет, что пользователь может вести записи по клетке:
+// работнику доступны только клетки его сотрудника, остальным ролям - все
+func (s *AuthService) CheckCageAccess(user *model.User, cageIDs ...uint) error {
+	return checkCageAccess(s.employeeRepo, user, cageIDs...)
+}
+
+func checkCageAccess(employeeRepo *repository.EmployeeRepository, user *model.User, cageIDs ...uint) error {
+	if user == nil || user.Role != model.RoleWorker {
+		return nil
+	}
+
+	if user.EmployeeID == nil {
+		return ErrCageForbidden
+	}
+
+	employee, err := employeeRepo.GetByID(*user.EmployeeID)
+	if err != nil {
+		return notFoundOr(err, ErrCageForbidden)
+	}
+
+	for _, cageID := range cageIDs {
+		if !slices.Contains(employee.Cages, cageID) {
+			return ErrCageForbidden
+		}
+	}
+
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+)
+
+type CageService struct {
+	farmRepo     *repository.FarmRepository
+	chickenRepo  *repository.ChickenRepository
+	employeeRepo *repository.EmployeeRepository
+}
+
+func NewCageService(
+	farmRepo *repository.FarmRepository,
+	chickenRepo *repository.ChickenRepository,
+	employeeRepo *repository.EmployeeRepository,
+) *CageService {
+	return &CageService{
+		farmRepo:     farmRepo,
+		chickenRepo:  chickenRepo,
+		employeeRepo: employeeRepo,
+	}
+}
+
+type CageOccupancy struct {
+	model.Cage
+	Occupancy int `json:"occupancy"`
+}
+
+type CageDetails struct {
+	model.Cage
+	Occupancy int              `json:"occupancy"`
+	Chickens  []model.Chicken  `json:"chickens"`
+	Employees []model.Employee `json:"employees"`
+}
+
+func (s *CageService) CreateCage(cage *model.Cage) error {
+	if cage.Capacity == 0 {
+		cage.Capacity = 1
+	}
+
+	if err := validateCage(cage); err != nil {
+		return err
+	}
+
+	existingCage, err := s.farmRepo.GetCageByNumber(cage.Number)
+	if err == nil && existingCage != nil {
+		return ErrCageNumberTaken
+	}
+
+	return s.farmRepo.CreateCage(cage)
+}
+
+func (s *CageService) GetAllCages() ([]CageOccupancy, error) {
+	cages, err := s.farmRepo.GetAllCages()
+	if err != nil {
+		return nil, err
+	}
+
+	occupancy, err := s.farmRepo.GetCageOccupancy()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CageOccupancy, 0, len(cages))
+	for _, cage := range cages {
+		result = append(result, CageOccupancy{
+			Cage:      cage,
+			Occupancy: occupancy[cage.ID],
+		})
+	}
+
+	return result, nil
+}
+
+func (s *CageService) GetEmptyCages() ([]model.Cage, error) {
+	return s.farmRepo.GetEmptyCages()
+}
+
+func (s *CageService) GetCageDetails(id uint) (*CageDetails, error) {
+	cage, err := s.farmRepo.GetCageByID(id)
+	if err != nil {
+		return nil, notFoundOr(err, ErrCageNotFound)
+	}
+
+	chickens, err := s.chickenRepo.GetAllByCageID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	employees, err := s.employeeRepo.GetByCageID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CageDetails{
+		Cage:      *cage,
+		Occupancy: len(chickens),
+		Chickens:  chickens,
+		Employees: employees,
+	}, nil
+}
+
+func (s *CageService) UpdateCage(cage *model.Cage) error {
+	oldCage, err := s.farmRepo.GetCageByID(cage.ID)
+	if err != nil {
+		return notFoundOr(err, ErrCageNotFound)
+	}
+
+	if cage.Capacity == 0 {
+		cage.Capacity = oldCage.Capacity
+	}
+	cage.CreatedAt = oldCage.CreatedAt
+
+	if err := validateCage(cage); err != nil {
+		return err
+	}
+
+	existingCage, err := s.farmRepo.GetCageByNumber(cage.Number)
+	if err == nil && existingCage != nil && existingCage.ID != cage.ID {
+		return ErrCageNumberTaken
+	}
+
+	// число кур сверяется с вместимостью в транзакции сохранения
+	err = s.farmRepo.UpdateCage(cage)
+	if errors.Is(err, repository.ErrCapacityBelowOccupancy) {
+		return conflictError("capacity_below_occupancy", "capacity is less than the number of chickens in the cage")
+	}
+	return notFoundOr(err, ErrCageNotFound)
+}
+
+func (s *CageService) DeleteCage(id uint) error {
+	// занятость клетки проверяется в одной транзакции с удалением
+	err := s.farmRepo.DeleteCage(id)
+	switch {
+	case errors.Is(err, repository.ErrCageHasChickens):
+		return ErrCageHasChickens
+	case errors.Is(err, repository.ErrCageHasEmployees):
+		return ErrCageHasEmployees
+	}
+	return notFoundOr(err, ErrCageNotFound)
+}
+
+// ReassignCage передает клетку сотруднику toEmployeeID. Если fromEmployeeID задан, клетка снимается
+// только с него, иначе - со всех, кто ее сейчас обслуживает. Изменение действует с сегодняшнего дня.
+func (s *CageService) ReassignCage(cageID uint, fromEmployeeID *uint, toEmployeeID uint) (*CageDetails, error) {
+	if _, err := s.farmRepo.GetCageByID(cageID); err != nil {
+		return nil, notFoundOr(err, ErrCageNotFound)
+	}
+
+	if fromEmployeeID != nil && *fromEmployeeID == toEmployeeID {
+		return nil, ErrSameEmployee
+	}
+
+	if _, err := s.employeeRepo.GetByID(toEmployeeID); err != nil {
+		return nil, notFoundOr(err, ErrEmployeeNotFound)
+	}
+
+	caretakers, err := s.employeeRepo.GetCaretakerIDs([]uint{cageID})
+	if err != nil {
+		return nil, err
+	}
+	current := caretakers[cageID]
+
+	from := current
+	if fromEmployeeID != nil {
+		if !slices.Contains(current, *fromEmployeeID) {
+			return nil, ErrCageNotAssigned
+		}
+		from = []uint{*fromEmployeeID}
+	}
+
+	exclusive, err := exclusiveAssignment(s.farmRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	// при исключительном закреплении у клетки не должно остаться других сотрудников;
+	// это проверяется под блокировкой клетки в транзакции передачи
+	move := repository.CageMove{CageID: cageID, FromEmployeeIDs: from, ToEmployeeID: toEmployeeID}
+	err = s.employeeRepo.ReassignCages([]repository.CageMove{move}, truncateToDay(time.Now()), exclusive)
+	if err != nil {
+		return nil, cageTakenOr(err)
+	}
+
+	return s.GetCageDetails(cageID)
+}
+
+func validateCage(cage *model.Cage) error {
+	cage.Row = strings.TrimSpace(cage.Row)
+	if len(cage.Row) > 50 {
+		return validationError("invalid_cage_row", "cage row must be at most 50 characters")
+	}
+
+	if cage.Number <= 0 {
+		return validationError("invalid_cage_number", "cage number must be positive")
+	}
+
+	if cage.Capacity < 1 {
+		return validationError("invalid_cage_capacity", "cage capacity must be at least 1")
+	}
+
+	return nil
+}
@@ -2,11 +2,20 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
 
 	"chicken-farm/internal/model"
 	"chicken-farm/internal/repository"
+
+	"gorm.io/gorm"
 )
 
+// maxChickenAge - предельный возраст курицы в месяцах
+const maxChickenAge = 240
+
 type ChickenService struct {
 	chickenRepo *repository.ChickenRepository
 	farmRepo    *repository.FarmRepository
@@ -23,79 +32,298 @@ func NewChickenService(
 }
 
 func (s *ChickenService) CreateChicken(chicken *model.Chicken) error {
-	_, err := s.farmRepo.GetCageByID(chicken.CageID)
-	if err != nil {
-		return errors.New("cage not found")
+	if err := resolveHatchDate(chicken); err != nil {
+		return err
 	}
 
-	existingChicken, err := s.chickenRepo.GetByCageID(chicken.CageID)
-	if err == nil && existingChicken != nil {
-		return errors.New("cage is already occupied by another chicken")
+	// место в клетке проверяется и занимается в одной транзакции
+	err := s.chickenRepo.Create(chicken, truncateToDay(time.Now()))
+	if errors.Is(err, repository.ErrCageFull) {
+		return ErrCageOccupied
 	}
-
-	return s.chickenRepo.Create(chicken)
+	return notFoundOr(err, ErrCageNotFound)
 }
 
 func (s *ChickenService) GetChickenByID(id uint) (*model.Chicken, error) {
-	return s.chickenRepo.GetByID(id)
-}
+	chicken, err := s.chickenRepo.GetByID(id)
+	if err != nil {
+		return nil, notFoundOr(err, ErrChickenNotFound)
+	}
 
-func (s *ChickenService) GetAllChickens() ([]model.Chicken, error) {
-	return s.chickenRepo.GetAll()
+	if err := fillLayingRate(s.farmRepo, chicken); err != nil {
+		return nil, err
+	}
+	return chicken, nil
 }
 
-func (s *ChickenService) UpdateChicken(chicken *model.Chicken) error {
-	_, err := s.chickenRepo.GetByID(chicken.ID)
+// ListChickens возвращает страницу кур и общее число кур под фильтром
+func (s *ChickenService) ListChickens(filter repository.ChickenFilter, list repository.ListQuery) ([]model.Chicken, int64, error) {
+	chickens, total, err := s.chickenRepo.List(filter, list)
 	if err != nil {
-		return errors.New("chicken not found")
+		return nil, 0, err
+	}
+
+	if err := fillLayingRates(s.farmRepo, chickens); err != nil {
+		return nil, 0, err
 	}
+	return chickens, total, nil
+}
 
+// UpdateChicken сохраняет курицу; если изменился вес, возвращает предупреждения о потере веса,
+// как при записи взвешивания
+func (s *ChickenService) UpdateChicken(chicken *model.Chicken) ([]WeightLossAlert, error) {
 	oldChicken, err := s.chickenRepo.GetByID(chicken.ID)
 	if err != nil {
-		return err
+		return nil, notFoundOr(err, ErrChickenNotFound)
+	}
+
+	// прежний возраст без даты вылупления не сдвигает уже известную дату
+	if chicken.HatchDate.IsZero() && (chicken.Age == 0 || chicken.Age == oldChicken.Age) {
+		chicken.HatchDate = oldChicken.HatchDate
+	}
+	if err := resolveHatchDate(chicken); err != nil {
+		return nil, err
 	}
 
 	if oldChicken.CageID != chicken.CageID {
-		_, err := s.farmRepo.GetCageByID(chicken.CageID)
-		if err != nil {
-			return errors.New("new cage not found")
+		if _, err := s.farmRepo.GetCageByID(chicken.CageID); err != nil {
+			return nil, notFoundOr(err, ErrCageNotFound)
 		}
+	}
 
-		existingChicken, err := s.chickenRepo.GetByCageID(chicken.CageID)
-		if err == nil && existingChicken != nil && existingChicken.ID != chicken.ID {
-			return errors.New("new cage is already occupied by another chicken")
-		}
+	// изменение веса записывается в историю как сегодняшнее взвешивание,
+	// переселение - как смена клетки с сегодняшнего дня
+	today := truncateToDay(time.Now())
+	var weighIn *model.WeighIn
+	if chicken.Weight != oldChicken.Weight {
+		weighIn = &model.WeighIn{ChickenID: chicken.ID, Date: today, Weight: chicken.Weight}
 	}
 
-	return s.chickenRepo.Update(chicken)
+	err = s.chickenRepo.Update(chicken, weighIn, today)
+	if errors.Is(err, repository.ErrCageFull) {
+		return nil, ErrCageOccupied
+	} else if err != nil {
+		return nil, notFoundOr(err, ErrChickenNotFound)
+	}
+
+	if weighIn == nil {
+		return []WeightLossAlert{}, nil
+	}
+	return s.weightLossAlerts([]model.WeighIn{*weighIn}, map[uint]map[time.Time]bool{
+		weighIn.ChickenID: {weighIn.Date: true},
+	})
 }
 
 func (s *ChickenService) DeleteChicken(id uint) error {
 	_, err := s.chickenRepo.GetByID(id)
 	if err != nil {
-		return errors.New("chicken not found")
+		return notFoundOr(err, ErrChickenNotFound)
 	}
 
 	return s.chickenRepo.Delete(id)
 }
 
+// WeightLossAlert - курица похудела между двумя взвешиваниями больше допустимого
+type WeightLossAlert struct {
+	ChickenID      uint      `json:"chicken_id"`
+	PreviousDate   time.Time `json:"previous_date"`
+	PreviousWeight float64   `json:"previous_weight"`
+	Date           time.Time `json:"date"`
+	Weight         float64   `json:"weight"`
+	LossPercent    float64   `json:"loss_percent"`
+}
+
+type WeighInResult struct {
+	WeighIns []model.WeighIn   `json:"weigh_ins"`
+	Alerts   []WeightLossAlert `json:"alerts"` // по записанным взвешиваниям
+}
+
+// RecordWeighIns записывает взвешивания: все или ни одного. Без даты взвешивание считается сегодняшним.
+func (s *ChickenService) RecordWeighIns(weighIns []model.WeighIn) (*WeighInResult, error) {
+	today := truncateToDay(time.Now())
+	seen := make(map[uint]map[time.Time]bool)
+
+	for i := range weighIns {
+		weighIn := &weighIns[i]
+		if weighIn.Date.IsZero() {
+			weighIn.Date = today
+		}
+		weighIn.Date = truncateToDay(weighIn.Date)
+		if weighIn.Date.After(today) {
+			return nil, ErrFutureDate
+		}
+
+		chicken, err := s.chickenRepo.GetByID(weighIn.ChickenID)
+		if err != nil {
+			return nil, notFoundOr(err, ErrChickenNotFound)
+		}
+		if weighIn.Date.Before(chicken.HatchDate.Time) {
+			return nil, ErrWeighInBeforeHatch
+		}
+
+		if seen[weighIn.ChickenID][weighIn.Date] {
+			return nil, ErrDuplicateWeighIn
+		}
+		if seen[weighIn.ChickenID] == nil {
+			seen[weighIn.ChickenID] = make(map[time.Time]bool)
+		}
+		seen[weighIn.ChickenID][weighIn.Date] = true
+
+		if _, err := s.chickenRepo.GetWeighInByDate(weighIn.ChickenID, weighIn.Date); err == nil {
+			return nil, ErrDuplicateWeighIn
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if err := s.chickenRepo.CreateWeighIns(weighIns); err != nil {
+		return nil, err
+	}
+
+	alerts, err := s.weightLossAlerts(weighIns, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WeighInResult{WeighIns: weighIns, Alerts: alerts}, nil
+}
+
+// weightLossAlerts сравнивает только что записанные взвешивания с соседними по дате.
+// recorded отмечает записанные дни каждой курицы, чтобы пара из двух новых взвешиваний проверялась один раз.
+func (s *ChickenService) weightLossAlerts(weighIns []model.WeighIn, recorded map[uint]map[time.Time]bool) ([]WeightLossAlert, error) {
+	threshold, err := weightLossThreshold(s.farmRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []WeightLossAlert{}
+	for _, weighIn := range weighIns {
+		previous, err := s.chickenRepo.GetPreviousWeighIn(weighIn.ChickenID, weighIn.Date)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if alert, ok := checkWeightLoss(*previous, weighIn, threshold); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	// задним числом записанное взвешивание становится предыдущим для следующего
+	for _, weighIn := range weighIns {
+		next, err := s.chickenRepo.GetNextWeighIn(weighIn.ChickenID, weighIn.Date)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if recorded[next.ChickenID][next.Date] {
+			continue
+		}
+
+		if alert, ok := checkWeightLoss(weighIn, *next, threshold); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return alerts, nil
+}
+
+// GetWeighIns возвращает историю веса курицы, начиная с ранних взвешиваний
+func (s *ChickenService) GetWeighIns(chickenID uint, startDate, endDate *time.Time) ([]model.WeighIn, error) {
+	if _, err := s.chickenRepo.GetByID(chickenID); err != nil {
+		return nil, notFoundOr(err, ErrChickenNotFound)
+	}
+
+	return s.chickenRepo.FindWeighIns(repository.WeighInFilter{
+		ChickenID: chickenID,
+		StartDate: startDate,
+		EndDate:   endDate,
+	})
+}
+
 func (s *ChickenService) GetAvgEggsByWeightAndAge(weight float64, age int) (float64, error) {
 	return s.chickenRepo.GetAvgEggsByWeightAndAge(weight, age)
 }
 
 func (s *ChickenService) GetChickensWithLowProductivity() ([]model.Chicken, error) {
-	return s.chickenRepo.GetChickensWithLowProductivity()
+	return lowProductivityChickens(s.chickenRepo, s.farmRepo)
 }
 
 func (s *ChickenService) GetMostProductiveChicken() (*model.Chicken, error) {
-	return s.chickenRepo.GetMostProductiveChicken()
+	chicken, _, err := mostProductiveChicken(s.chickenRepo, s.farmRepo)
+	return chicken, err
 }
 
 func (s *ChickenService) GetCageWithMostEggs() (*model.Cage, error) {
 	cageID, err := s.farmRepo.GetCageWithMostEggs()
 	if err != nil {
-		return nil, err
+		return nil, notFoundOr(err, ErrCageNotFound)
+	}
+
+	cage, err := s.farmRepo.GetCageByID(cageID)
+	if err != nil {
+		return nil, notFoundOr(err, ErrCageNotFound)
+	}
+	return cage, nil
+}
+
+// resolveHatchDate проверяет дату вылупления и заполняет по ней возраст.
+// Старые клиенты присылают только возраст, тогда дата вылупления оценивается по нему.
+func resolveHatchDate(chicken *model.Chicken) error {
+	today := truncateToDay(time.Now())
+
+	if chicken.HatchDate.IsZero() {
+		if chicken.Age == 0 {
+			return validationError("hatch_date_required", "hatch_date or age is required")
+		}
+		chicken.HatchDate = model.NewDate(model.HatchDateForAge(chicken.Age, today))
+	}
+
+	chicken.HatchDate = model.NewDate(chicken.HatchDate.Time)
+	if chicken.HatchDate.After(today) {
+		return validationError("invalid_hatch_date", "hatch_date cannot be in the future")
+	}
+
+	chicken.Age = model.AgeInMonths(chicken.HatchDate.Time, today)
+	if chicken.Age > maxChickenAge {
+		return validationError("invalid_hatch_date", "chicken cannot be older than 240 months")
+	}
+	return nil
+}
+
+// weightLossThreshold возвращает допустимую потерю веса между взвешиваниями в процентах
+func weightLossThreshold(farmRepo *repository.FarmRepository) (float64, error) {
+	value, err := configValue(farmRepo, model.ConfigWeightLossAlert)
+	if err != nil {
+		return 0, err
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", model.ConfigWeightLossAlert, err)
+	}
+	return threshold, nil
+}
+
+// checkWeightLoss сравнивает взвешивание с предыдущим и сообщает, похудела ли курица больше чем на threshold процентов
+func checkWeightLoss(previous, current model.WeighIn, threshold float64) (WeightLossAlert, bool) {
+	// у старых записей вес мог остаться нулевым, процент потери от нуля не определен
+	if previous.Weight <= 0 {
+		return WeightLossAlert{}, false
+	}
+
+	loss := (previous.Weight - current.Weight) / previous.Weight * 100
+	if loss <= threshold {
+		return WeightLossAlert{}, false
 	}
 
-	return s.farmRepo.GetCageByID(cageID)
+	return WeightLossAlert{
+		ChickenID:      current.ChickenID,
+		PreviousDate:   previous.Date,
+		PreviousWeight: previous.Weight,
+		Date:           current.Date,
+		Weight:         current.Weight,
+		LossPercent:    math.Round(loss*10) / 10,
+	}, true
 }
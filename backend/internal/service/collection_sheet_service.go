@@ -0,0 +1,202 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+)
+
+type CollectionSheetService struct {
+	chickenRepo  *repository.ChickenRepository
+	farmRepo     *repository.FarmRepository
+	employeeRepo *repository.EmployeeRepository
+	shiftRepo    *repository.ShiftRepository
+}
+
+func NewCollectionSheetService(
+	chickenRepo *repository.ChickenRepository,
+	farmRepo *repository.FarmRepository,
+	employeeRepo *repository.EmployeeRepository,
+	shiftRepo *repository.ShiftRepository,
+) *CollectionSheetService {
+	return &CollectionSheetService{
+		chickenRepo:  chickenRepo,
+		farmRepo:     farmRepo,
+		employeeRepo: employeeRepo,
+		shiftRepo:    shiftRepo,
+	}
+}
+
+type CollectionSheetEntry struct {
+	CageID     uint        `json:"cage_id"`
+	CageNumber int         `json:"cage_number"`
+	ChickenID  uint        `json:"chicken_id"`
+	Breed      string      `json:"breed"`
+	Record     *model.Farm `json:"record"` // nil, если за этот день еще ничего не отмечено
+}
+
+type CollectionSheet struct {
+	Date    time.Time              `json:"date"`
+	Entries []CollectionSheetEntry `json:"entries"`
+}
+
+type CollectionSheetMark struct {
+	ChickenID uint `json:"chicken_id" binding:"required"`
+	CageID    uint `json:"cage_id"` // необязательно, если указан - должен совпадать с клеткой курицы
+	HasEgg    bool `json:"has_egg"`
+}
+
+// GetSheet возвращает все занятые клетки с курами и отметками за указанный день
+func (s *CollectionSheetService) GetSheet(date time.Time) (*CollectionSheet, error) {
+	date = truncateToDay(date)
+
+	chickens, err := s.chickenRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	cages, err := s.farmRepo.GetAllCages()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.farmRepo.GetByDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	cagesOnDate, err := s.chickenRepo.GetCagesOnDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	cageNumbers := make(map[uint]int, len(cages))
+	for _, cage := range cages {
+		cageNumbers[cage.ID] = cage.Number
+	}
+
+	recordsByChicken := make(map[uint]*model.Farm, len(records))
+	for i := range records {
+		recordsByChicken[records[i].ChickenID] = &records[i]
+	}
+
+	entries := make([]CollectionSheetEntry, 0, len(chickens))
+	for _, chicken := range chickens {
+		// в листе курица стоит в клетке, где жила в тот день, а уже записанная отметка - в своей
+		cageID, present := cagesOnDate[chicken.ID]
+		if record := recordsByChicken[chicken.ID]; record != nil {
+			cageID, present = record.CageID, true
+		}
+		if !present {
+			continue
+		}
+
+		entries = append(entries, CollectionSheetEntry{
+			CageID:     cageID,
+			CageNumber: cageNumbers[cageID],
+			ChickenID:  chicken.ID,
+			Breed:      chicken.Breed,
+			Record:     recordsByChicken[chicken.ID],
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CageNumber != entries[j].CageNumber {
+			return entries[i].CageNumber < entries[j].CageNumber
+		}
+		return entries[i].ChickenID < entries[j].ChickenID
+	})
+
+	return &CollectionSheet{
+		Date:    date,
+		Entries: entries,
+	}, nil
+}
+
+// SaveSheet сохраняет отметки обхода за день целиком: либо все, либо ни одной.
+// Работник может отмечать только кур в своих клетках.
+func (s *CollectionSheetService) SaveSheet(user *model.User, date time.Time, marks []CollectionSheetMark) (*CollectionSheet, error) {
+	date = truncateToDay(date)
+
+	if date.After(truncateToDay(time.Now())) {
+		return nil, ErrFutureDate
+	}
+	if err := checkOnShift(s.shiftRepo, user, date); err != nil {
+		return nil, err
+	}
+
+	chickens, err := s.chickenRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	chickensByID := make(map[uint]model.Chicken, len(chickens))
+	for _, chicken := range chickens {
+		chickensByID[chicken.ID] = chicken
+	}
+
+	existing, err := s.farmRepo.GetByDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	cagesOnDate, err := s.chickenRepo.GetCagesOnDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	// уже записанная отметка остается в своей клетке, даже если курицу потом пересадили
+	cageByChicken := make(map[uint]uint, len(existing))
+	for _, record := range existing {
+		cageByChicken[record.ChickenID] = record.CageID
+	}
+
+	records := make([]model.Farm, 0, len(marks))
+	seen := make(map[uint]bool, len(marks))
+	for _, mark := range marks {
+		chicken, ok := chickensByID[mark.ChickenID]
+		if !ok {
+			return nil, notFoundError("chicken_not_found", fmt.Sprintf("chicken %d not found", mark.ChickenID))
+		}
+
+		if seen[mark.ChickenID] {
+			return nil, validationError("duplicate_chicken", fmt.Sprintf("chicken %d is listed more than once", mark.ChickenID))
+		}
+		seen[mark.ChickenID] = true
+
+		cageID, recorded := cageByChicken[chicken.ID]
+		if !recorded {
+			cageID, recorded = cagesOnDate[chicken.ID]
+		}
+		if !recorded {
+			return nil, validationError("chicken_not_in_cage", fmt.Sprintf("chicken %d was not in the cage on that date", mark.ChickenID))
+		}
+
+		if mark.CageID != 0 && mark.CageID != cageID {
+			return nil, validationError("chicken_not_in_cage", fmt.Sprintf("chicken %d is not in cage %d", mark.ChickenID, mark.CageID))
+		}
+
+		records = append(records, model.Farm{
+			CageID:    cageID,
+			ChickenID: chicken.ID,
+			HasEgg:    mark.HasEgg,
+		})
+	}
+
+	cageIDs := make([]uint, 0, len(records))
+	for _, record := range records {
+		cageIDs = append(cageIDs, record.CageID)
+	}
+	if err := checkCageAccess(s.employeeRepo, user, cageIDs...); err != nil {
+		return nil, err
+	}
+
+	if err := s.farmRepo.SaveDay(date, records); err != nil {
+		return nil, err
+	}
+
+	return s.GetSheet(date)
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type configParamSpec struct {
+	defaultValue string
+	// normalize проверяет значение и приводит его к виду, в котором оно хранится
+	normalize func(value string) (string, error)
+	// save сохраняет значение, если для параметра недостаточно записи в config_params
+	save func(farmRepo *repository.FarmRepository, value string) error
+}
+
+// knownConfigParams описывает все параметры, которые можно менять через API
+var knownConfigParams = map[string]configParamSpec{
+	model.ConfigEggPrice: {
+		defaultValue: strconv.FormatFloat(model.DefaultEggPrice, 'f', -1, 64),
+		normalize:    positiveFloat,
+		save:         saveEggPrice,
+	},
+	model.ConfigCageAssignmentPolicy: {
+		defaultValue: model.CageAssignmentShared,
+		normalize:    oneOf(model.CageAssignmentShared, model.CageAssignmentExclusive),
+		save:         saveCageAssignmentPolicy,
+	},
+	model.ConfigPayrollEggTarget: {
+		defaultValue: "0",
+		normalize:    nonNegativeInt,
+	},
+	model.ConfigPayrollEggBonus: {
+		defaultValue: "0",
+		normalize:    nonNegativeFloat,
+	},
+	model.ConfigWeightLossAlert: {
+		defaultValue: "10",
+		normalize:    percent,
+	},
+	model.ConfigEggRateSource: {
+		defaultValue: model.EggRateManual,
+		normalize:    oneOf(model.EggRateManual, model.EggRateComputed),
+	},
+}
+
+type ConfigService struct {
+	farmRepo *repository.FarmRepository
+}
+
+func NewConfigService(farmRepo *repository.FarmRepository) *ConfigService {
+	return &ConfigService{
+		farmRepo: farmRepo,
+	}
+}
+
+// GetAllParams возвращает все известные параметры, для незаданных - значения по умолчанию
+func (s *ConfigService) GetAllParams() ([]model.ConfigParam, error) {
+	stored, err := s.farmRepo.GetAllConfigParams()
+	if err != nil {
+		return nil, err
+	}
+
+	storedByKey := make(map[string]model.ConfigParam, len(stored))
+	for _, param := range stored {
+		storedByKey[param.Key] = param
+	}
+
+	params := make([]model.ConfigParam, 0, len(knownConfigParams))
+	for key, spec := range knownConfigParams {
+		param, ok := storedByKey[key]
+		if !ok {
+			param = model.ConfigParam{Key: key, Value: spec.defaultValue}
+		}
+		params = append(params, param)
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Key < params[j].Key
+	})
+
+	return params, nil
+}
+
+func (s *ConfigService) GetParam(key string) (*model.ConfigParam, error) {
+	spec, ok := knownConfigParams[key]
+	if !ok {
+		return nil, ErrUnknownConfigKey
+	}
+
+	value, err := s.farmRepo.GetConfigParam(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		value = spec.defaultValue
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &model.ConfigParam{Key: key, Value: value}, nil
+}
+
+func (s *ConfigService) SetParam(key, value string) (*model.ConfigParam, error) {
+	spec, ok := knownConfigParams[key]
+	if !ok {
+		return nil, ErrUnknownConfigKey
+	}
+
+	value, err := spec.normalize(strings.TrimSpace(value))
+	if err != nil {
+		return nil, validationError("invalid_config_value", fmt.Sprintf("invalid value for %s: %s", key, err))
+	}
+
+	save := spec.save
+	if save == nil {
+		save = func(farmRepo *repository.FarmRepository, value string) error {
+			return farmRepo.UpdateConfigParam(key, value)
+		}
+	}
+
+	if err := save(s.farmRepo, value); err != nil {
+		return nil, err
+	}
+
+	return &model.ConfigParam{Key: key, Value: value}, nil
+}
+
+func positiveFloat(value string) (string, error) {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return "", errors.New("must be a number")
+	}
+
+	if number <= 0 {
+		return "", errors.New("must be positive")
+	}
+
+	return strconv.FormatFloat(number, 'f', -1, 64), nil
+}
+
+func nonNegativeInt(value string) (string, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return "", errors.New("must be an integer")
+	}
+
+	if number < 0 {
+		return "", errors.New("must not be negative")
+	}
+
+	return strconv.Itoa(number), nil
+}
+
+func nonNegativeFloat(value string) (string, error) {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return "", errors.New("must be a number")
+	}
+
+	if number < 0 {
+		return "", errors.New("must not be negative")
+	}
+
+	return strconv.FormatFloat(number, 'f', -1, 64), nil
+}
+
+func percent(value string) (string, error) {
+	value, err := positiveFloat(value)
+	if err != nil {
+		return "", err
+	}
+
+	if number, _ := strconv.ParseFloat(value, 64); number > 100 {
+		return "", errors.New("must not exceed 100")
+	}
+
+	return value, nil
+}
+
+func oneOf(values ...string) func(value string) (string, error) {
+	return func(value string) (string, error) {
+		if !slices.Contains(values, value) {
+			return "", fmt.Errorf("must be one of: %s", strings.Join(values, ", "))
+		}
+		return value, nil
+	}
+}
+
+// saveCageAssignmentPolicy не дает включить исключительное закрепление,
+// пока у каких-то клеток несколько сотрудников
+func saveCageAssignmentPolicy(farmRepo *repository.FarmRepository, value string) error {
+	if value == model.CageAssignmentExclusive {
+		shared, err := farmRepo.GetSharedCages()
+		if err != nil {
+			return err
+		}
+
+		if len(shared) > 0 {
+			numbers := make([]string, len(shared))
+			for i, cage := range shared {
+				numbers[i] = strconv.Itoa(cage.Number)
+			}
+			return conflictError("cages_shared",
+				"cages are assigned to several employees: "+strings.Join(numbers, ", "))
+		}
+	}
+
+	return farmRepo.UpdateConfigParam(model.ConfigCageAssignmentPolicy, value)
+}
+
+// configValue возвращает значение параметра или его значение по умолчанию, если параметр не задан
+func configValue(farmRepo *repository.FarmRepository, key string) (string, error) {
+	value, err := farmRepo.GetConfigParam(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return knownConfigParams[key].defaultValue, nil
+	}
+	return value, err
+}
+
+// exclusiveAssignment сообщает, действует ли исключительное закрепление клеток
+func exclusiveAssignment(farmRepo *repository.FarmRepository) (bool, error) {
+	policy, err := configValue(farmRepo, model.ConfigCageAssignmentPolicy)
+	return policy == model.CageAssignmentExclusive, err
+}
+
+// saveEggPrice записывает новую цену в историю цен начиная с сегодняшнего дня
+func saveEggPrice(farmRepo *repository.FarmRepository, value string) error {
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+
+	return farmRepo.SaveEggPrice(&model.EggPrice{
+		Price:         price,
+		EffectiveFrom: truncateToDay(time.Now()),
+	})
+}
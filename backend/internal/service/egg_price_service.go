@@ -0,0 +1,51 @@
+package service
+
+import (
+	"math"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+)
+
+type EggPriceService struct {
+	farmRepo *repository.FarmRepository
+}
+
+func NewEggPriceService(farmRepo *repository.FarmRepository) *EggPriceService {
+	return &EggPriceService{
+		farmRepo: farmRepo,
+	}
+}
+
+func (s *EggPriceService) GetPriceHistory() ([]model.EggPrice, error) {
+	return s.farmRepo.GetEggPrices()
+}
+
+func (s *EggPriceService) SetPrice(price *model.EggPrice) error {
+	if price.Price <= 0 || math.IsInf(price.Price, 0) || math.IsNaN(price.Price) {
+		return validationError("invalid_egg_price", "price must be positive")
+	}
+
+	price.EffectiveFrom = truncateToDay(price.EffectiveFrom)
+
+	return s.farmRepo.SaveEggPrice(price)
+}
+
+func (s *EggPriceService) DeletePrice(id uint) error {
+	price, err := s.farmRepo.GetEggPriceByID(id)
+	if err != nil {
+		return notFoundOr(err, ErrEggPriceNotFound)
+	}
+
+	prices, err := s.farmRepo.GetEggPrices()
+	if err != nil {
+		return err
+	}
+
+	// без самой ранней цены стоимость старых записей будет считаться по текущей цене
+	if len(prices) > 1 && prices[0].ID == price.ID {
+		return ErrInitialEggPrice
+	}
+
+	return s.farmRepo.DeleteEggPrice(id)
+}
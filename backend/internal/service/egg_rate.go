@@ -0,0 +1,153 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+)
+
+// layingWindowDays - длина скользящего окна, по которому считается яйценоскость
+const layingWindowDays = 30
+
+// layingWindow возвращает окно [start, end) из layingWindowDays дней, заканчивающееся днем today
+func layingWindow(today time.Time) (time.Time, time.Time) {
+	return today.AddDate(0, 0, -(layingWindowDays - 1)), today.AddDate(0, 0, 1)
+}
+
+// layingRate переводит яйца, снесенные за окно, в яйца за 30 дней. Курица, которая на ферме
+// меньше 30 дней, оценивается по дням, которые она провела на ферме.
+func layingRate(eggs int, chicken model.Chicken, today time.Time) float64 {
+	start, _ := layingWindow(today)
+	days := layingWindowDays
+	if arrived := truncateToDay(chicken.CreatedAt); arrived.After(start) {
+		days = int(today.Sub(arrived).Hours()/24) + 1
+	}
+
+	return roundRate(float64(eggs) * layingWindowDays / float64(max(days, 1)))
+}
+
+// fillLayingRates считает яйценоскость кур одним запросом к farm_records
+func fillLayingRates(farmRepo *repository.FarmRepository, chickens []model.Chicken) error {
+	today := truncateToDay(time.Now())
+	start, end := layingWindow(today)
+
+	counts, err := farmRepo.GetEggCountsByChicken(start, end)
+	if err != nil {
+		return err
+	}
+
+	for i := range chickens {
+		rate := layingRate(counts[chickens[i].ID], chickens[i], today)
+		chickens[i].LayingRate = &rate
+	}
+	return nil
+}
+
+// fillLayingRate считает яйценоскость одной курицы по ее записям
+func fillLayingRate(farmRepo *repository.FarmRepository, chicken *model.Chicken) error {
+	records, err := farmRepo.GetByChickenID(chicken.ID)
+	if err != nil {
+		return err
+	}
+
+	today := truncateToDay(time.Now())
+	start, end := layingWindow(today)
+	eggs := 0
+	for _, record := range records {
+		if record.HasEgg && !record.Date.Before(start) && record.Date.Before(end) {
+			eggs++
+		}
+	}
+
+	rate := layingRate(eggs, *chicken, today)
+	chicken.LayingRate = &rate
+	return nil
+}
+
+// rankedChickens возвращает всех кур с яйценоскостью, по которой их сравнивают рейтинги:
+// введенной вручную или посчитанной по записям, в зависимости от egg_rate_source.
+// Куры отсортированы от самой продуктивной.
+func rankedChickens(
+	chickenRepo *repository.ChickenRepository,
+	farmRepo *repository.FarmRepository,
+) ([]model.Chicken, []float64, error) {
+	source, err := configValue(farmRepo, model.ConfigEggRateSource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chickens, err := chickenRepo.GetAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := fillLayingRates(farmRepo, chickens); err != nil {
+		return nil, nil, err
+	}
+
+	rate := func(chicken model.Chicken) float64 {
+		if source == model.EggRateComputed {
+			return *chicken.LayingRate
+		}
+		return float64(chicken.EggPerMonth)
+	}
+
+	sort.SliceStable(chickens, func(i, j int) bool {
+		if rate(chickens[i]) != rate(chickens[j]) {
+			return rate(chickens[i]) > rate(chickens[j])
+		}
+		return chickens[i].ID < chickens[j].ID
+	})
+
+	rates := make([]float64, len(chickens))
+	for i, chicken := range chickens {
+		rates[i] = rate(chicken)
+	}
+	return chickens, rates, nil
+}
+
+// lowProductivityChickens возвращает кур, чья яйценоскость ниже средней по ферме
+func lowProductivityChickens(
+	chickenRepo *repository.ChickenRepository,
+	farmRepo *repository.FarmRepository,
+) ([]model.Chicken, error) {
+	chickens, rates, err := rankedChickens(chickenRepo, farmRepo)
+	if err != nil {
+		return nil, err
+	}
+	if len(chickens) == 0 {
+		return []model.Chicken{}, nil
+	}
+
+	var total float64
+	for _, rate := range rates {
+		total += rate
+	}
+	average := total / float64(len(rates))
+
+	low := []model.Chicken{}
+	for i, chicken := range chickens {
+		if rates[i] < average {
+			low = append(low, chicken)
+		}
+	}
+
+	sort.Slice(low, func(i, j int) bool { return low[i].ID < low[j].ID })
+	return low, nil
+}
+
+// mostProductiveChicken возвращает курицу с наибольшей яйценоскостью и саму яйценоскость
+func mostProductiveChicken(
+	chickenRepo *repository.ChickenRepository,
+	farmRepo *repository.FarmRepository,
+) (*model.Chicken, float64, error) {
+	chickens, rates, err := rankedChickens(chickenRepo, farmRepo)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(chickens) == 0 {
+		return nil, 0, ErrChickenNotFound
+	}
+	return &chickens[0], rates[0], nil
+}
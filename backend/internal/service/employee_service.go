@@ -2,9 +2,12 @@ package service
 
 import (
 	"errors"
+	"time"
 
 	"chicken-farm/internal/model"
 	"chicken-farm/internal/repository"
+
+	"gorm.io/gorm"
 )
 
 type EmployeeService struct {
@@ -28,50 +31,90 @@ func (s *EmployeeService) CreateEmployee(employee *model.Employee) error {
 	for _, cageID := range employee.Cages {
 		_, err := s.farmRepo.GetCageByID(cageID)
 		if err != nil {
-			return errors.New("cage not found")
+			return notFoundOr(err, ErrCageNotFound)
 		}
 	}
 
-	return s.employeeRepo.Create(employee)
+	if err := s.checkPassportFree(employee); err != nil {
+		return err
+	}
+
+	exclusive, err := exclusiveAssignment(s.farmRepo)
+	if err != nil {
+		return err
+	}
+
+	// занятость клеток проверяется в транзакции записи, под блокировкой клеток
+	err = s.employeeRepo.Create(employee, truncateToDay(time.Now()), exclusive)
+	return cageTakenOr(err)
 }
 
 func (s *EmployeeService) GetEmployeeByID(id uint) (*model.Employee, error) {
-	return s.employeeRepo.GetByID(id)
+	employee, err := s.employeeRepo.GetByID(id)
+	if err != nil {
+		return nil, notFoundOr(err, ErrEmployeeNotFound)
+	}
+	return employee, nil
 }
 
-func (s *EmployeeService) GetAllEmployees() ([]model.Employee, error) {
-	return s.employeeRepo.GetAll()
+// ListEmployees возвращает страницу сотрудников и общее число сотрудников под фильтром
+func (s *EmployeeService) ListEmployees(filter repository.EmployeeFilter, list repository.ListQuery) ([]model.Employee, int64, error) {
+	return s.employeeRepo.List(filter, list)
 }
 
+// UpdateEmployee обновляет сотрудника; если паспорт не передан, остается прежний
 func (s *EmployeeService) UpdateEmployee(employee *model.Employee) error {
-	_, err := s.employeeRepo.GetByID(employee.ID)
+	oldEmployee, err := s.employeeRepo.GetByID(employee.ID)
 	if err != nil {
-		return errors.New("employee not found")
+		return notFoundOr(err, ErrEmployeeNotFound)
+	}
+
+	if employee.PassportData == "" {
+		employee.PassportData = oldEmployee.PassportData
+	} else if err := s.checkPassportFree(employee); err != nil {
+		return err
 	}
 
 	for _, cageID := range employee.Cages {
 		_, err := s.farmRepo.GetCageByID(cageID)
 		if err != nil {
-			return errors.New("cage not found")
+			return notFoundOr(err, ErrCageNotFound)
 		}
 	}
 
-	return s.employeeRepo.Update(employee)
+	exclusive, err := exclusiveAssignment(s.farmRepo)
+	if err != nil {
+		return err
+	}
+
+	// изменения закреплений действуют с сегодняшнего дня, история до него не меняется
+	err = s.employeeRepo.Update(employee, truncateToDay(time.Now()), exclusive)
+	return cageTakenOr(err)
+}
+
+// GetCageHistory возвращает периоды, в которые сотрудник обслуживал клетки
+func (s *EmployeeService) GetCageHistory(employeeID uint) ([]model.EmployeeCage, error) {
+	_, err := s.employeeRepo.GetByID(employeeID)
+	if err != nil {
+		return nil, notFoundOr(err, ErrEmployeeNotFound)
+	}
+
+	return s.employeeRepo.GetCageHistory(employeeID)
 }
 
 func (s *EmployeeService) DeleteEmployee(id uint) error {
 	_, err := s.employeeRepo.GetByID(id)
 	if err != nil {
-		return errors.New("employee not found")
+		return notFoundOr(err, ErrEmployeeNotFound)
 	}
 
-	return s.employeeRepo.Delete(id)
+	return s.employeeRepo.Delete(id, truncateToDay(time.Now()))
 }
 
 func (s *EmployeeService) GetEmployeeChickenCount(employeeID uint) (int, error) {
 	_, err := s.employeeRepo.GetByID(employeeID)
 	if err != nil {
-		return 0, errors.New("employee not found")
+		return 0, notFoundOr(err, ErrEmployeeNotFound)
 	}
 
 	return s.employeeRepo.GetEmployeeChickenCount(employeeID)
@@ -84,7 +127,7 @@ func (s *EmployeeService) GetAllEmployeeChickenCounts() (map[uint]int, error) {
 func (s *EmployeeService) GetEmployeeEggCount(employeeID uint, startDate, endDate string) (int, error) {
 	_, err := s.employeeRepo.GetByID(employeeID)
 	if err != nil {
-		return 0, errors.New("employee not found")
+		return 0, notFoundOr(err, ErrEmployeeNotFound)
 	}
 
 	return s.employeeRepo.GetEmployeeEggCount(employeeID, startDate, endDate)
@@ -93,3 +136,21 @@ func (s *EmployeeService) GetEmployeeEggCount(employeeID uint, startDate, endDat
 func (s *EmployeeService) GetAllEmployeeEggCounts(startDate, endDate string) (map[uint]int, error) {
 	return s.employeeRepo.GetAllEmployeeEggCounts(startDate, endDate)
 }
+
+func (s *EmployeeService) checkPassportFree(employee *model.Employee) error {
+	existing, err := s.employeeRepo.GetByPassport(employee.PassportData)
+	if err == nil && existing.ID != employee.ID {
+		return ErrPassportTaken
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// cageTakenOr переводит ошибки записи закреплений в ошибки сервиса
+func cageTakenOr(err error) error {
+	if errors.Is(err, repository.ErrCageTaken) {
+		return ErrCageTaken
+	}
+	return notFoundOr(err, ErrCageNotFound)
+}
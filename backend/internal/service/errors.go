@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Виды ошибок предметной области. Проверяются через errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// Error - ошибка предметной области с машиночитаемым кодом
+type Error struct {
+	Kind    error  // ErrNotFound, ErrConflict, ErrValidation, ErrUnauthorized или ErrForbidden
+	Code    string // стабильный код для клиентов, например "cage_not_found"
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func notFoundError(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func conflictError(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+func validationError(code, message string) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message}
+}
+
+func unauthorizedError(code, message string) *Error {
+	return &Error{Kind: ErrUnauthorized, Code: code, Message: message}
+}
+
+func forbiddenError(code, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+var (
+	ErrCageNotFound       = notFoundError("cage_not_found", "cage not found")
+	ErrChickenNotFound    = notFoundError("chicken_not_found", "chicken not found")
+	ErrEmployeeNotFound   = notFoundError("employee_not_found", "employee not found")
+	ErrFarmRecordNotFound = notFoundError("farm_record_not_found", "farm record not found")
+	ErrEggPriceNotFound   = notFoundError("egg_price_not_found", "egg price not found")
+	ErrUnknownConfigKey   = notFoundError("unknown_config_key", "unknown config key")
+	ErrUserNotFound       = notFoundError("user_not_found", "user not found")
+	ErrPayrollNotFound    = notFoundError("payroll_not_found", "payroll for this period has not been calculated")
+	ErrAbsenceNotFound    = notFoundError("absence_not_found", "absence not found")
+	ErrShiftNotFound      = notFoundError("shift_not_found", "shift not found")
+
+	ErrFutureDate            = validationError("future_date", "date cannot be in the future")
+	ErrWorkerWithoutEmployee = validationError("employee_required", "a worker account must be linked to an employee")
+	ErrUnknownRole           = validationError("unknown_role", "role must be one of: admin, manager, worker")
+	ErrSameEmployee          = validationError("same_employee", "cage is reassigned to the employee it is taken from")
+	ErrWeighInBeforeHatch    = validationError("weigh_in_before_hatch", "chicken cannot be weighed before it hatched")
+
+	ErrCageOccupied        = conflictError("cage_occupied", "cage is already occupied by another chicken")
+	ErrCageNumberTaken     = conflictError("cage_number_taken", "cage with this number already exists")
+	ErrCageHasChickens     = conflictError("cage_has_chickens", "cage is occupied by a chicken")
+	ErrCageHasEmployees    = conflictError("cage_has_employees", "cage is assigned to employees")
+	ErrCageTaken           = conflictError("cage_taken", "cage is already assigned to another employee")
+	ErrCageNotAssigned     = conflictError("cage_not_assigned", "cage is not assigned to this employee")
+	ErrDuplicateFarmRecord = conflictError("duplicate_farm_record", "record for this chicken on this date already exists")
+	ErrInitialEggPrice     = conflictError("initial_egg_price", "the initial egg price cannot be deleted")
+	ErrPassportTaken       = conflictError("passport_taken", "employee with this passport already exists")
+	ErrUsernameTaken       = conflictError("username_taken", "user with this username already exists")
+	ErrLastAdmin           = conflictError("last_admin", "the last admin cannot be deleted or demoted")
+	ErrPayrollFinalized    = conflictError("payroll_finalized", "payroll for this period is finalized and cannot be changed")
+	ErrDuplicateAbsence    = conflictError("duplicate_absence", "absence for this employee on this date already exists")
+	ErrShiftOverlap        = conflictError("shift_overlap", "shift overlaps another shift of this employee")
+	ErrShiftStarted        = conflictError("shift_started", "shift already has clock-ins and cannot be deleted")
+	ErrAlreadyClockedIn    = conflictError("already_clocked_in", "employee is already clocked in")
+	ErrNotClockedIn        = conflictError("not_clocked_in", "employee is not clocked in")
+	ErrDuplicateWeighIn    = conflictError("duplicate_weigh_in", "chicken has already been weighed on this date")
+
+	ErrAuthRequired       = unauthorizedError("auth_required", "authentication required")
+	ErrInvalidCredentials = unauthorizedError("invalid_credentials", "invalid username or password")
+	ErrInvalidToken       = unauthorizedError("invalid_token", "session token is invalid or expired")
+
+	ErrRoleForbidden  = forbiddenError("role_forbidden", "your role does not allow this action")
+	ErrCageForbidden  = forbiddenError("cage_forbidden", "workers can only record eggs in their own cages")
+	ErrNotOnShift     = forbiddenError("not_on_shift", "workers can only enter farm records while clocked in")
+	ErrClockForbidden = forbiddenError("clock_forbidden", "workers can only clock themselves in and out")
+	ErrNotOnSiteDay   = forbiddenError("not_on_site_that_day", "workers can only enter farm records for days they were clocked in")
+)
+
+// notFoundOr заменяет gorm.ErrRecordNotFound на ошибку предметной области, остальные ошибки возвращает как есть
+func notFoundOr(err error, notFound *Error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound
+	}
+	return err
+}
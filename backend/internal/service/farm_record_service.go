@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type FarmRecordService struct {
+	farmRepo     *repository.FarmRepository
+	chickenRepo  *repository.ChickenRepository
+	employeeRepo *repository.EmployeeRepository
+	shiftRepo    *repository.ShiftRepository
+}
+
+func NewFarmRecordService(
+	farmRepo *repository.FarmRepository,
+	chickenRepo *repository.ChickenRepository,
+	employeeRepo *repository.EmployeeRepository,
+	shiftRepo *repository.ShiftRepository,
+) *FarmRecordService {
+	return &FarmRecordService{
+		farmRepo:     farmRepo,
+		chickenRepo:  chickenRepo,
+		employeeRepo: employeeRepo,
+		shiftRepo:    shiftRepo,
+	}
+}
+
+// CreateRecord создает запись от имени user; работник может писать только в свои клетки
+// и только во время смены
+func (s *FarmRecordService) CreateRecord(user *model.User, record *model.Farm) error {
+	record.Date = truncateToDay(record.Date)
+
+	if err := checkCageAccess(s.employeeRepo, user, record.CageID); err != nil {
+		return err
+	}
+	if err := checkOnShift(s.shiftRepo, user, record.Date); err != nil {
+		return err
+	}
+
+	if err := s.validateRecord(record, nil); err != nil {
+		return err
+	}
+
+	existing, err := s.farmRepo.GetByChickenAndDate(record.ChickenID, record.Date)
+	if err == nil && existing != nil {
+		return ErrDuplicateFarmRecord
+	}
+
+	return s.farmRepo.Create(record)
+}
+
+func (s *FarmRecordService) GetRecordByID(id uint) (*model.Farm, error) {
+	record, err := s.farmRepo.GetByID(id)
+	if err != nil {
+		return nil, notFoundOr(err, ErrFarmRecordNotFound)
+	}
+	return record, nil
+}
+
+func (s *FarmRecordService) GetRecords(filter repository.FarmRecordFilter) ([]model.Farm, error) {
+	return s.farmRepo.Find(filter)
+}
+
+func (s *FarmRecordService) UpdateRecord(user *model.User, record *model.Farm) error {
+	oldRecord, err := s.farmRepo.GetByID(record.ID)
+	if err != nil {
+		return notFoundOr(err, ErrFarmRecordNotFound)
+	}
+
+	record.Date = truncateToDay(record.Date)
+
+	if err := checkCageAccess(s.employeeRepo, user, oldRecord.CageID, record.CageID); err != nil {
+		return err
+	}
+	if err := checkOnShift(s.shiftRepo, user, oldRecord.Date, record.Date); err != nil {
+		return err
+	}
+
+	record.CreatedAt = oldRecord.CreatedAt
+
+	if err := s.validateRecord(record, oldRecord); err != nil {
+		return err
+	}
+
+	existing, err := s.farmRepo.GetByChickenAndDate(record.ChickenID, record.Date)
+	if err == nil && existing != nil && existing.ID != record.ID {
+		return ErrDuplicateFarmRecord
+	}
+
+	return s.farmRepo.Update(record)
+}
+
+func (s *FarmRecordService) DeleteRecord(user *model.User, id uint) error {
+	record, err := s.farmRepo.GetByID(id)
+	if err != nil {
+		return notFoundOr(err, ErrFarmRecordNotFound)
+	}
+
+	if err := checkCageAccess(s.employeeRepo, user, record.CageID); err != nil {
+		return err
+	}
+	if err := checkOnShift(s.shiftRepo, user, record.Date); err != nil {
+		return err
+	}
+
+	return s.farmRepo.Delete(id)
+}
+
+// validateRecord проверяет по истории клеток, что курица действительно сидела в указанной клетке в этот день.
+// Клетка старой записи (oldRecord) повторно не проверяется: запись могла появиться раньше истории клеток.
+func (s *FarmRecordService) validateRecord(record, oldRecord *model.Farm) error {
+	if record.Date.After(truncateToDay(time.Now())) {
+		return ErrFutureDate
+	}
+
+	_, err := s.farmRepo.GetCageByID(record.CageID)
+	if err != nil {
+		return notFoundOr(err, ErrCageNotFound)
+	}
+
+	if _, err := s.chickenRepo.GetByID(record.ChickenID); err != nil {
+		return notFoundOr(err, ErrChickenNotFound)
+	}
+
+	if oldRecord != nil && oldRecord.ChickenID == record.ChickenID && oldRecord.CageID == record.CageID {
+		return nil
+	}
+
+	cageID, err := s.chickenRepo.GetCageOnDate(record.ChickenID, record.Date)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return validationError("chicken_not_in_cage", "chicken was not in the given cage on that date")
+	} else if err != nil {
+		return err
+	}
+
+	if cageID != record.CageID {
+		return validationError("chicken_not_in_cage", "chicken is not in the given cage on that date")
+	}
+
+	return nil
+}
+
+// truncateToDay приводит время к началу дня в UTC, в таком виде даты хранятся в farm_records
+func truncateToDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
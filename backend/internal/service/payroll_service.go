@@ -0,0 +1,370 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// форматы расчетного периода и дат в запросах к репозиторию
+const (
+	periodLayout = "2006-01"
+	dateLayout   = "2006-01-02"
+)
+
+type PayrollService struct {
+	payrollRepo  *repository.PayrollRepository
+	employeeRepo *repository.EmployeeRepository
+	farmRepo     *repository.FarmRepository
+	shiftRepo    *repository.ShiftRepository
+}
+
+func NewPayrollService(
+	payrollRepo *repository.PayrollRepository,
+	employeeRepo *repository.EmployeeRepository,
+	farmRepo *repository.FarmRepository,
+	shiftRepo *repository.ShiftRepository,
+) *PayrollService {
+	return &PayrollService{
+		payrollRepo:  payrollRepo,
+		employeeRepo: employeeRepo,
+		farmRepo:     farmRepo,
+		shiftRepo:    shiftRepo,
+	}
+}
+
+// Payroll - расчетные листки всех сотрудников за месяц
+type Payroll struct {
+	Period   string          `json:"period"`
+	Status   string          `json:"status"` // draft или finalized
+	Total    float64         `json:"total"`
+	Payslips []model.Payslip `json:"payslips"`
+}
+
+// Calculate рассчитывает зарплату за месяц и сохраняет листки черновиками, заменяя прежние.
+// К окладу добавляется премия за яйца сверх плана, за пропущенные дни удерживается
+// дневная ставка: оклад, деленный на число рабочих дней месяца. Пропущенным считается
+// рабочий день с записанным пропуском или со сменой, на которую сотрудник не пришел.
+// Принятому в середине месяца оклад платится за рабочие дни с даты приема (дня создания
+// карточки), принятые после конца месяца в расчет не попадают.
+func (s *PayrollService) Calculate(period string) (*Payroll, error) {
+	start, end, err := periodRange(period)
+	if err != nil {
+		return nil, err
+	}
+
+	if start.After(truncateToDay(time.Now())) {
+		return nil, validationError("future_period", "payroll cannot be calculated for a future month")
+	}
+
+	finalized, err := s.payrollRepo.IsFinalized(period)
+	if err != nil {
+		return nil, err
+	}
+	if finalized {
+		return nil, ErrPayrollFinalized
+	}
+
+	eggTarget, eggBonus, err := s.bonusSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	employees, err := s.employeeRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	lastDay := end.AddDate(0, 0, -1).Format(dateLayout)
+	eggCounts, err := s.employeeRepo.GetAllEmployeeEggCounts(start.Format(dateLayout), lastDay)
+	if err != nil {
+		return nil, err
+	}
+
+	absences, err := s.payrollRepo.GetAbsences(start, end)
+	if err != nil {
+		return nil, err
+	}
+	attendance, err := collectAttendance(s.shiftRepo, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	employedFrom := make(map[uint]time.Time, len(employees))
+	for _, employee := range employees {
+		employedFrom[employee.ID] = maxTime(start, truncateToDay(employee.CreatedAt))
+	}
+
+	// день, отмеченный и пропуском, и пропущенной сменой, удерживается один раз;
+	// выходные и дни до приема не удерживаются
+	missedDays := make(map[uint]map[string]bool)
+	markMissed := func(employeeID uint, day time.Time) {
+		if !isWorkingDay(day) || day.Before(employedFrom[employeeID]) {
+			return
+		}
+		if missedDays[employeeID] == nil {
+			missedDays[employeeID] = make(map[string]bool)
+		}
+		missedDays[employeeID][day.Format(dateLayout)] = true
+	}
+	for _, absence := range absences {
+		markMissed(absence.EmployeeID, truncateToDay(absence.Date))
+	}
+	for employeeID, employee := range attendance {
+		for _, day := range employee.MissedDates {
+			date, err := time.Parse(dateLayout, day)
+			if err != nil {
+				return nil, err
+			}
+			markMissed(employeeID, date)
+		}
+	}
+
+	workingDays := countWorkingDays(start, end)
+
+	payslips := make([]model.Payslip, 0, len(employees))
+	for _, employee := range employees {
+		from := employedFrom[employee.ID]
+		if !from.Before(end) {
+			continue
+		}
+		payslips = append(payslips, buildPayslip(period, employee, eggCounts[employee.ID], len(missedDays[employee.ID]),
+			countWorkingDays(from, end), workingDays, eggTarget, eggBonus))
+	}
+
+	ok, err := s.payrollRepo.ReplaceDrafts(period, payslips)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrPayrollFinalized
+	}
+
+	return s.GetPayroll(period)
+}
+
+func (s *PayrollService) GetPayroll(period string) (*Payroll, error) {
+	if _, _, err := periodRange(period); err != nil {
+		return nil, err
+	}
+
+	payslips, err := s.payrollRepo.GetPayslips(period)
+	if err != nil {
+		return nil, err
+	}
+	if len(payslips) == 0 {
+		return nil, ErrPayrollNotFound
+	}
+
+	payroll := &Payroll{
+		Period:   period,
+		Status:   model.PayslipDraft,
+		Payslips: payslips,
+	}
+	for _, payslip := range payslips {
+		payroll.Total = roundMoney(payroll.Total + payslip.Total)
+		if payslip.Status == model.PayslipFinalized {
+			payroll.Status = model.PayslipFinalized
+		}
+	}
+
+	return payroll, nil
+}
+
+// Finalize утверждает листки за месяц; после этого их нельзя пересчитать
+func (s *PayrollService) Finalize(period string) (*Payroll, error) {
+	payroll, err := s.GetPayroll(period)
+	if err != nil {
+		return nil, err
+	}
+	if payroll.Status == model.PayslipFinalized {
+		return nil, ErrPayrollFinalized
+	}
+
+	if _, err := s.payrollRepo.Finalize(period, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s.GetPayroll(period)
+}
+
+func (s *PayrollService) CreateAbsence(absence *model.Absence) error {
+	if _, err := s.employeeRepo.GetByID(absence.EmployeeID); err != nil {
+		return notFoundOr(err, ErrEmployeeNotFound)
+	}
+
+	if err := s.checkPeriodOpen(absence.Date); err != nil {
+		return err
+	}
+
+	_, err := s.payrollRepo.GetAbsenceByEmployeeAndDate(absence.EmployeeID, absence.Date)
+	if err == nil {
+		return ErrDuplicateAbsence
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return s.payrollRepo.CreateAbsence(absence)
+}
+
+func (s *PayrollService) GetAbsences(period string) ([]model.Absence, error) {
+	start, end, err := periodRange(period)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.payrollRepo.GetAbsences(start, end)
+}
+
+func (s *PayrollService) DeleteAbsence(id uint) error {
+	absence, err := s.payrollRepo.GetAbsenceByID(id)
+	if err != nil {
+		return notFoundOr(err, ErrAbsenceNotFound)
+	}
+
+	if err := s.checkPeriodOpen(absence.Date); err != nil {
+		return err
+	}
+
+	return s.payrollRepo.DeleteAbsence(id)
+}
+
+// checkPeriodOpen не дает менять пропуски в месяце с утвержденной зарплатой
+func (s *PayrollService) checkPeriodOpen(date time.Time) error {
+	finalized, err := s.payrollRepo.IsFinalized(date.Format(periodLayout))
+	if err != nil {
+		return err
+	}
+	if finalized {
+		return ErrPayrollFinalized
+	}
+	return nil
+}
+
+func (s *PayrollService) bonusSettings() (int, float64, error) {
+	value, err := configValue(s.farmRepo, model.ConfigPayrollEggTarget)
+	if err != nil {
+		return 0, 0, err
+	}
+	target, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s: %w", model.ConfigPayrollEggTarget, err)
+	}
+
+	value, err = configValue(s.farmRepo, model.ConfigPayrollEggBonus)
+	if err != nil {
+		return 0, 0, err
+	}
+	bonus, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s: %w", model.ConfigPayrollEggBonus, err)
+	}
+
+	return target, bonus, nil
+}
+
+func buildPayslip(
+	period string,
+	employee model.Employee,
+	eggCount, absenceDays, employedDays, workingDays, eggTarget int,
+	eggBonus float64,
+) model.Payslip {
+	payslip := model.Payslip{
+		Period:       period,
+		EmployeeID:   employee.ID,
+		EmployeeName: employee.FullName,
+		EggCount:     eggCount,
+		AbsenceDays:  absenceDays,
+		BaseSalary:   roundMoney(employee.Salary),
+		Status:       model.PayslipDraft,
+	}
+
+	if employedDays < workingDays {
+		payslip.BaseSalary = roundMoney(employee.Salary * float64(employedDays) / float64(workingDays))
+		payslip.Lines = append(payslip.Lines, model.PayslipLine{
+			Kind:        model.PayslipLineSalary,
+			Description: fmt.Sprintf("Base salary for %d of %d working days since hiring", employedDays, workingDays),
+			Quantity:    float64(employedDays),
+			Rate:        roundMoney(employee.Salary / float64(workingDays)),
+			Amount:      payslip.BaseSalary,
+		})
+	} else {
+		payslip.Lines = append(payslip.Lines, model.PayslipLine{
+			Kind:        model.PayslipLineSalary,
+			Description: "Base salary",
+			Quantity:    1,
+			Rate:        payslip.BaseSalary,
+			Amount:      payslip.BaseSalary,
+		})
+	}
+
+	if extra := eggCount - eggTarget; extra > 0 && eggBonus > 0 {
+		payslip.Bonus = roundMoney(float64(extra) * eggBonus)
+		payslip.Lines = append(payslip.Lines, model.PayslipLine{
+			Kind:        model.PayslipLineEggBonus,
+			Description: fmt.Sprintf("Bonus for %d eggs above the target of %d", extra, eggTarget),
+			Quantity:    float64(extra),
+			Rate:        eggBonus,
+			Amount:      payslip.Bonus,
+		})
+	}
+
+	if days := min(absenceDays, employedDays); days > 0 {
+		dailyRate := employee.Salary / float64(workingDays)
+		// удержание не может превысить оклад
+		payslip.Deductions = min(roundMoney(float64(days)*dailyRate), payslip.BaseSalary)
+		payslip.Lines = append(payslip.Lines, model.PayslipLine{
+			Kind:        model.PayslipLineAbsence,
+			Description: fmt.Sprintf("Deduction for %d absence days out of %d working days", days, workingDays),
+			Quantity:    float64(days),
+			Rate:        -roundMoney(dailyRate),
+			Amount:      -payslip.Deductions,
+		})
+	}
+
+	payslip.Total = roundMoney(payslip.BaseSalary + payslip.Bonus - payslip.Deductions)
+	return payslip
+}
+
+// periodRange переводит месяц "YYYY-MM" в полуинтервал [первый день, первый день следующего месяца)
+func periodRange(period string) (time.Time, time.Time, error) {
+	start, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return time.Time{}, time.Time{}, validationError("invalid_period", "period must be a month in the format YYYY-MM")
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// countWorkingDays считает дни с понедельника по пятницу в полуинтервале [start, end)
+func countWorkingDays(start, end time.Time) int {
+	days := 0
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if isWorkingDay(day) {
+			days++
+		}
+	}
+	return days
+}
+
+func isWorkingDay(day time.Time) bool {
+	weekday := day.Weekday()
+	return weekday != time.Saturday && weekday != time.Sunday
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"math"
+	"sort"
+
+	"chicken-farm/internal/model"
+)
+
+// maxBands ограничивает число полос по каждой оси матрицы
+const maxBands = 100
+
+// BandSpec задает полосы по одной оси: явными границами или шириной полосы
+type BandSpec struct {
+	Edges []float64 // возрастающие границы; полоса i - [Edges[i], Edges[i+1])
+	Step  float64   // ширина полосы, если границы не заданы; полосы покрывают все значения
+}
+
+// Band - полуинтервал [From, To)
+type Band struct {
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+}
+
+// ProductivityCell - яйценоскость кур одной полосы веса и одной полосы возраста.
+// Если кур в ячейке нет, статистика равна nil.
+type ProductivityCell struct {
+	SampleSize int      `json:"sample_size"`
+	Avg        *float64 `json:"avg"`
+	Median     *float64 `json:"median"`
+	Min        *float64 `json:"min"`
+	Max        *float64 `json:"max"`
+}
+
+// ProductivityMatrix - строки соответствуют полосам веса, столбцы - полосам возраста
+type ProductivityMatrix struct {
+	EggRateSource string               `json:"egg_rate_source"`
+	WeightBands   []Band               `json:"weight_bands"` // в килограммах
+	AgeBands      []Band               `json:"age_bands"`    // в месяцах
+	Cells         [][]ProductivityCell `json:"cells"`
+}
+
+// GetProductivityMatrix раскладывает кур по полосам веса и возраста и считает яйценоскость в каждой ячейке.
+// Яйценоскость берется так же, как в рейтингах кур, в зависимости от egg_rate_source.
+func (s *ReportService) GetProductivityMatrix(weightSpec, ageSpec BandSpec) (*ProductivityMatrix, error) {
+	source, err := configValue(s.farmRepo, model.ConfigEggRateSource)
+	if err != nil {
+		return nil, err
+	}
+
+	chickens, rates, err := rankedChickens(s.chickenRepo, s.farmRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make([]float64, len(chickens))
+	ages := make([]float64, len(chickens))
+	for i, chicken := range chickens {
+		weights[i] = chicken.Weight
+		ages[i] = float64(chicken.Age)
+	}
+
+	weightBands, err := buildBands("weight", weightSpec, weights)
+	if err != nil {
+		return nil, err
+	}
+	ageBands, err := buildBands("age", ageSpec, ages)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([][][]float64, len(weightBands))
+	for i := range samples {
+		samples[i] = make([][]float64, len(ageBands))
+	}
+	for i := range chickens {
+		row, ok := bandIndex(weightBands, weights[i])
+		if !ok {
+			continue
+		}
+		column, ok := bandIndex(ageBands, ages[i])
+		if !ok {
+			continue
+		}
+		samples[row][column] = append(samples[row][column], rates[i])
+	}
+
+	matrix := &ProductivityMatrix{
+		EggRateSource: source,
+		WeightBands:   weightBands,
+		AgeBands:      ageBands,
+		Cells:         make([][]ProductivityCell, len(weightBands)),
+	}
+	for row := range samples {
+		matrix.Cells[row] = make([]ProductivityCell, len(ageBands))
+		for column, values := range samples[row] {
+			matrix.Cells[row][column] = productivityCell(values)
+		}
+	}
+
+	return matrix, nil
+}
+
+// buildBands строит полосы по явным границам или по ширине так, чтобы они покрыли все значения
+func buildBands(axis string, spec BandSpec, values []float64) ([]Band, error) {
+	if len(spec.Edges) > 0 {
+		if len(spec.Edges) < 2 {
+			return nil, validationError("invalid_bands", axis+" needs at least two band edges")
+		}
+		if len(spec.Edges)-1 > maxBands {
+			return nil, validationError("too_many_bands", axis+" cannot have more than 100 bands")
+		}
+
+		for _, edge := range spec.Edges {
+			if math.IsNaN(edge) || math.IsInf(edge, 0) {
+				return nil, validationError("invalid_bands", axis+" band edges must be finite numbers")
+			}
+		}
+
+		bands := make([]Band, 0, len(spec.Edges)-1)
+		for i := 1; i < len(spec.Edges); i++ {
+			if spec.Edges[i] <= spec.Edges[i-1] {
+				return nil, validationError("invalid_bands", axis+" band edges must be increasing")
+			}
+			bands = append(bands, Band{From: spec.Edges[i-1], To: spec.Edges[i]})
+		}
+		return bands, nil
+	}
+
+	if spec.Step <= 0 || math.IsNaN(spec.Step) || math.IsInf(spec.Step, 0) {
+		return nil, validationError("invalid_bands", axis+" band width must be positive")
+	}
+	if len(values) == 0 {
+		return []Band{}, nil
+	}
+
+	low, high := values[0], values[0]
+	for _, value := range values {
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+
+	first := math.Floor(low/spec.Step) * spec.Step
+	// частное сравнивается до перевода в int: при крошечной ширине оно не помещается в int
+	quotient := math.Floor((high - first) / spec.Step)
+	if math.IsNaN(quotient) || quotient+1 > maxBands {
+		return nil, validationError("too_many_bands", axis+" band width is too small for the data")
+	}
+	count := int(quotient) + 1
+
+	bands := make([]Band, count)
+	for i := range bands {
+		// границы считаются от начала, чтобы не накапливать ошибку округления
+		bands[i] = Band{From: roundEdge(first + float64(i)*spec.Step), To: roundEdge(first + float64(i+1)*spec.Step)}
+	}
+	return bands, nil
+}
+
+func bandIndex(bands []Band, value float64) (int, bool) {
+	index := sort.Search(len(bands), func(i int) bool { return bands[i].To > value })
+	if index == len(bands) || value < bands[index].From {
+		return 0, false
+	}
+	return index, true
+}
+
+func productivityCell(values []float64) ProductivityCell {
+	cell := ProductivityCell{SampleSize: len(values)}
+	if len(values) == 0 {
+		return cell
+	}
+
+	sort.Float64s(values)
+	var total float64
+	for _, value := range values {
+		total += value
+	}
+
+	middle := len(values) / 2
+	median := values[middle]
+	if len(values)%2 == 0 {
+		median = (values[middle-1] + values[middle]) / 2
+	}
+
+	avg := roundRate(total / float64(len(values)))
+	median = roundRate(median)
+	cell.Avg = &avg
+	cell.Median = &median
+	cell.Min = &values[0]
+	cell.Max = &values[len(values)-1]
+	return cell
+}
+
+func roundRate(rate float64) float64 {
+	return math.Round(rate*10) / 10
+}
+
+func roundEdge(edge float64) float64 {
+	return math.Round(edge*1e6) / 1e6
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"sort"
+	"time"
+
 	"chicken-farm/internal/model"
 	"chicken-farm/internal/repository"
 )
@@ -9,17 +12,20 @@ type ReportService struct {
 	chickenRepo  *repository.ChickenRepository
 	employeeRepo *repository.EmployeeRepository
 	farmRepo     *repository.FarmRepository
+	shiftRepo    *repository.ShiftRepository
 }
 
 func NewReportService(
 	chickenRepo *repository.ChickenRepository,
 	employeeRepo *repository.EmployeeRepository,
 	farmRepo *repository.FarmRepository,
+	shiftRepo *repository.ShiftRepository,
 ) *ReportService {
 	return &ReportService{
 		chickenRepo:  chickenRepo,
 		employeeRepo: employeeRepo,
 		farmRepo:     farmRepo,
+		shiftRepo:    shiftRepo,
 	}
 }
 
@@ -28,8 +34,10 @@ func (s *ReportService) GetAvgEggsByWeightAndAge(weight float64, age int) (float
 }
 
 type EggStats struct {
-	TotalEggs int     `json:"total_eggs"`
-	TotalCost float64 `json:"total_cost"`
+	TotalEggs    int                         `json:"total_eggs"`
+	TotalCost    float64                     `json:"total_cost"`
+	EggPrice     float64                     `json:"egg_price"`     // цена, действующая сегодня
+	PricePeriods []repository.EggPricePeriod `json:"price_periods"` // стоимость по периодам действия цен
 }
 
 func (s *ReportService) GetTotalEggStats(startDate, endDate string) (*EggStats, error) {
@@ -38,25 +46,83 @@ func (s *ReportService) GetTotalEggStats(startDate, endDate string) (*EggStats,
 		return nil, err
 	}
 
-	cost, err := s.farmRepo.GetTotalEggCost(startDate, endDate)
+	periods, err := s.farmRepo.GetEggCostByPricePeriod(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var cost float64
+	for _, period := range periods {
+		cost += period.Cost
+	}
+
+	eggPrice, err := s.farmRepo.GetEggPrice()
 	if err != nil {
 		return nil, err
 	}
 
 	return &EggStats{
-		TotalEggs: count,
-		TotalCost: cost,
+		TotalEggs:    count,
+		TotalCost:    cost,
+		EggPrice:     eggPrice,
+		PricePeriods: periods,
 	}, nil
 }
 
 type EmployeeEggStats struct {
-	EmployeeID   uint   `json:"employee_id"`
-	EmployeeName string `json:"employee_name"`
-	EggCount     int    `json:"egg_count"`
+	EmployeeID   uint    `json:"employee_id"`
+	EmployeeName string  `json:"employee_name"`
+	EggCount     int     `json:"egg_count"`
+	HoursWorked  float64 `json:"hours_worked"`  // по отметкам прихода и ухода за период
+	EggsPerHour  float64 `json:"eggs_per_hour"` // 0, если отработанных часов нет
 }
 
 func (s *ReportService) GetEmployeeEggStats(startDate, endDate string) ([]EmployeeEggStats, error) {
-	eggCounts, err := s.employeeRepo.GetAllEmployeeEggCounts(startDate, endDate)
+	eggCounts, err := s.employeeRepo.CountEggsByEmployee(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := time.Parse(dateLayout, startDate)
+	if err != nil {
+		return nil, validationError("invalid_date", "start_date must be in the format YYYY-MM-DD")
+	}
+	end, err := time.Parse(dateLayout, endDate)
+	if err != nil {
+		return nil, validationError("invalid_date", "end_date must be in the format YYYY-MM-DD")
+	}
+	attendance, err := collectAttendance(s.shiftRepo, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]EmployeeEggStats, 0, len(eggCounts))
+	for _, eggCount := range eggCounts {
+		employeeStats := EmployeeEggStats{
+			EmployeeID:   eggCount.EmployeeID,
+			EmployeeName: eggCount.EmployeeName,
+			EggCount:     eggCount.Count,
+		}
+		if worked := attendance[eggCount.EmployeeID]; worked != nil && worked.WorkedHours > 0 {
+			employeeStats.HoursWorked = worked.WorkedHours
+			employeeStats.EggsPerHour = roundHours(float64(eggCount.Count) / worked.WorkedHours)
+		}
+
+		stats = append(stats, employeeStats)
+	}
+
+	return stats, nil
+}
+
+// GetAttendance возвращает посещаемость всех сотрудников за месяц "YYYY-MM":
+// запланированные и отработанные часы, пропущенные смены и опоздания
+func (s *ReportService) GetAttendance(period string) ([]EmployeeAttendance, error) {
+	start, end, err := periodRange(period)
+	if err != nil {
+		return nil, err
+	}
+
+	attendance, err := collectAttendance(s.shiftRepo, start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -66,43 +132,83 @@ func (s *ReportService) GetEmployeeEggStats(startDate, endDate string) ([]Employ
 		return nil, err
 	}
 
-	stats := make([]EmployeeEggStats, 0, len(employees))
+	report := make([]EmployeeAttendance, 0, len(employees))
 	for _, employee := range employees {
-		eggCount, ok := eggCounts[employee.ID]
-		if !ok {
-			eggCount = 0
+		row := EmployeeAttendance{EmployeeID: employee.ID, MissedDates: []string{}}
+		if collected := attendance[employee.ID]; collected != nil {
+			row = *collected
 		}
+		row.EmployeeName = employee.FullName
+		report = append(report, row)
+	}
 
-		stats = append(stats, EmployeeEggStats{
-			EmployeeID:   employee.ID,
-			EmployeeName: employee.FullName,
-			EggCount:     eggCount,
-		})
+	sort.Slice(report, func(i, j int) bool { return report[i].EmployeeID < report[j].EmployeeID })
+	return report, nil
+}
+
+// GetWeightLossAlerts возвращает взвешивания с start_date по end_date включительно,
+// при которых курица похудела с прошлого взвешивания больше допустимого
+func (s *ReportService) GetWeightLossAlerts(startDate, endDate string) ([]WeightLossAlert, error) {
+	start, err := time.Parse(dateLayout, startDate)
+	if err != nil {
+		return nil, validationError("invalid_date", "start_date must be in the format YYYY-MM-DD")
+	}
+	end, err := time.Parse(dateLayout, endDate)
+	if err != nil {
+		return nil, validationError("invalid_date", "end_date must be in the format YYYY-MM-DD")
 	}
 
-	return stats, nil
+	threshold, err := weightLossThreshold(s.farmRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	weighIns, err := s.chickenRepo.GetWeighInsWithHistory(start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	// взвешивания идут по курам, внутри курицы - по датам
+	alerts := []WeightLossAlert{}
+	for i := 1; i < len(weighIns); i++ {
+		previous, current := weighIns[i-1], weighIns[i]
+		if previous.ChickenID != current.ChickenID || current.Date.Before(start) {
+			continue
+		}
+		if alert, ok := checkWeightLoss(previous, current, threshold); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return alerts, nil
+}
+
+// GetUnattendedCages возвращает клетки без закрепленного сотрудника
+func (s *ReportService) GetUnattendedCages() ([]model.Cage, error) {
+	return s.farmRepo.GetUnattendedCages()
 }
 
 func (s *ReportService) GetLowProductivityChickens() ([]model.Chicken, error) {
-	return s.chickenRepo.GetChickensWithLowProductivity()
+	return lowProductivityChickens(s.chickenRepo, s.farmRepo)
 }
 
 type MostProductiveChickenStats struct {
-	ChickenID   uint `json:"chicken_id"`
-	CageID      uint `json:"cage_id"`
-	CageNumber  int  `json:"cage_number"`
-	EggPerMonth int  `json:"egg_per_month"`
+	ChickenID   uint    `json:"chicken_id"`
+	CageID      uint    `json:"cage_id"`
+	CageNumber  int     `json:"cage_number"`
+	EggPerMonth int     `json:"egg_per_month"`
+	LayingRate  float64 `json:"laying_rate"` // яиц за последние 30 дней по записям farm_records
 }
 
 func (s *ReportService) GetMostProductiveChickenStats() (*MostProductiveChickenStats, error) {
-	chicken, err := s.chickenRepo.GetMostProductiveChicken()
+	chicken, _, err := mostProductiveChicken(s.chickenRepo, s.farmRepo)
 	if err != nil {
 		return nil, err
 	}
 
 	cage, err := s.farmRepo.GetCageByID(chicken.CageID)
 	if err != nil {
-		return nil, err
+		return nil, notFoundOr(err, ErrCageNotFound)
 	}
 
 	return &MostProductiveChickenStats{
@@ -110,6 +216,7 @@ func (s *ReportService) GetMostProductiveChickenStats() (*MostProductiveChickenS
 		CageID:      chicken.CageID,
 		CageNumber:  cage.Number,
 		EggPerMonth: chicken.EggPerMonth,
+		LayingRate:  *chicken.LayingRate,
 	}, nil
 }
 
@@ -120,27 +227,17 @@ type EmployeeChickenCountStats struct {
 }
 
 func (s *ReportService) GetEmployeeChickenCountStats() ([]EmployeeChickenCountStats, error) {
-	chickenCounts, err := s.employeeRepo.GetAllEmployeeChickenCounts()
+	chickenCounts, err := s.employeeRepo.CountChickensByEmployee()
 	if err != nil {
 		return nil, err
 	}
 
-	employees, err := s.employeeRepo.GetAll()
-	if err != nil {
-		return nil, err
-	}
-
-	stats := make([]EmployeeChickenCountStats, 0, len(employees))
-	for _, employee := range employees {
-		chickenCount, ok := chickenCounts[employee.ID]
-		if !ok {
-			chickenCount = 0
-		}
-
+	stats := make([]EmployeeChickenCountStats, 0, len(chickenCounts))
+	for _, chickenCount := range chickenCounts {
 		stats = append(stats, EmployeeChickenCountStats{
-			EmployeeID:   employee.ID,
-			EmployeeName: employee.FullName,
-			ChickenCount: chickenCount,
+			EmployeeID:   chickenCount.EmployeeID,
+			EmployeeName: chickenCount.EmployeeName,
+			ChickenCount: chickenCount.Count,
 		})
 	}
 
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// clockInEarly - насколько раньше начала смены можно на нее отметиться
+	clockInEarly = time.Hour
+	// lateGrace - опоздание меньше этого не считается
+	lateGrace = 5 * time.Minute
+	// maxShiftLength - самая длинная смена, которую можно запланировать
+	maxShiftLength = 24 * time.Hour
+)
+
+type ShiftService struct {
+	shiftRepo    *repository.ShiftRepository
+	employeeRepo *repository.EmployeeRepository
+}
+
+func NewShiftService(
+	shiftRepo *repository.ShiftRepository,
+	employeeRepo *repository.EmployeeRepository,
+) *ShiftService {
+	return &ShiftService{
+		shiftRepo:    shiftRepo,
+		employeeRepo: employeeRepo,
+	}
+}
+
+func (s *ShiftService) CreateShift(shift *model.Shift) error {
+	if _, err := s.employeeRepo.GetByID(shift.EmployeeID); err != nil {
+		return notFoundOr(err, ErrEmployeeNotFound)
+	}
+
+	shift.StartsAt = shift.StartsAt.UTC()
+	shift.EndsAt = shift.EndsAt.UTC()
+
+	if !shift.EndsAt.After(shift.StartsAt) {
+		return validationError("invalid_shift_time", "shift must end after it starts")
+	}
+	if shift.EndsAt.Sub(shift.StartsAt) > maxShiftLength {
+		return validationError("invalid_shift_time", "shift cannot be longer than 24 hours")
+	}
+
+	overlaps, err := s.shiftRepo.HasOverlappingShift(shift.EmployeeID, shift.StartsAt, shift.EndsAt)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return ErrShiftOverlap
+	}
+
+	return s.shiftRepo.CreateShift(shift)
+}
+
+// GetShifts возвращает смены за период; работник видит только свои
+func (s *ShiftService) GetShifts(user *model.User, filter repository.ShiftFilter) ([]model.Shift, error) {
+	if user != nil && user.Role == model.RoleWorker {
+		if user.EmployeeID == nil {
+			return []model.Shift{}, nil
+		}
+		filter.EmployeeID = *user.EmployeeID
+	}
+
+	return s.shiftRepo.FindShifts(filter)
+}
+
+func (s *ShiftService) DeleteShift(id uint) error {
+	if _, err := s.shiftRepo.GetShiftByID(id); err != nil {
+		return notFoundOr(err, ErrShiftNotFound)
+	}
+
+	entries, err := s.shiftRepo.CountTimeEntriesForShift(id)
+	if err != nil {
+		return err
+	}
+	if entries > 0 {
+		return ErrShiftStarted
+	}
+
+	return s.shiftRepo.DeleteShift(id)
+}
+
+// ClockIn отмечает приход сотрудника. Работник отмечается сам, остальные роли указывают сотрудника.
+// Если у сотрудника есть смена, которая идет или скоро начнется, отметка привязывается к ней.
+func (s *ShiftService) ClockIn(user *model.User, employeeID *uint) (*model.TimeEntry, error) {
+	id, err := s.clockEmployee(user, employeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.shiftRepo.GetOpenTimeEntry(id); err == nil {
+		return nil, ErrAlreadyClockedIn
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	entry := &model.TimeEntry{
+		EmployeeID: id,
+		ClockInAt:  now,
+	}
+
+	shift, err := s.shiftRepo.FindShiftAt(id, now, clockInEarly)
+	if err == nil {
+		entry.ShiftID = &shift.ID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err := s.shiftRepo.CreateTimeEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *ShiftService) ClockOut(user *model.User, employeeID *uint) (*model.TimeEntry, error) {
+	id, err := s.clockEmployee(user, employeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := s.shiftRepo.GetOpenTimeEntry(id)
+	if err != nil {
+		return nil, notFoundOr(err, ErrNotClockedIn)
+	}
+
+	now := time.Now().UTC()
+	entry.ClockOutAt = &now
+	if err := s.shiftRepo.UpdateTimeEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *ShiftService) GetTimeEntries(start, end time.Time) ([]model.TimeEntry, error) {
+	return s.shiftRepo.GetTimeEntries(start, end)
+}
+
+// clockEmployee определяет, чей приход или уход отмечается
+func (s *ShiftService) clockEmployee(user *model.User, employeeID *uint) (uint, error) {
+	if user != nil && user.Role == model.RoleWorker {
+		if user.EmployeeID == nil || (employeeID != nil && *employeeID != *user.EmployeeID) {
+			return 0, ErrClockForbidden
+		}
+		return *user.EmployeeID, nil
+	}
+
+	if employeeID == nil {
+		return 0, validationError("employee_id_required", "employee_id is required")
+	}
+	if _, err := s.employeeRepo.GetByID(*employeeID); err != nil {
+		return 0, notFoundOr(err, ErrEmployeeNotFound)
+	}
+	return *employeeID, nil
+}
+
+// checkOnShift не дает работнику вести записи, если он не отметил приход, и пускает его
+// только в записи за дни dates, когда он был на ферме по отметкам.
+// Остальные роли записи проверяют и исправляют, им смена не нужна.
+func checkOnShift(shiftRepo *repository.ShiftRepository, user *model.User, dates ...time.Time) error {
+	if user == nil || user.Role != model.RoleWorker {
+		return nil
+	}
+
+	if user.EmployeeID == nil {
+		return ErrNotOnShift
+	}
+
+	if _, err := shiftRepo.GetOpenTimeEntry(*user.EmployeeID); err != nil {
+		return notFoundOr(err, ErrNotOnShift)
+	}
+
+	for _, date := range dates {
+		day := truncateToDay(date)
+		present, err := shiftRepo.HasTimeEntryBetween(*user.EmployeeID, day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		if !present {
+			return ErrNotOnSiteDay
+		}
+	}
+	return nil
+}
+
+// EmployeeAttendance - посещаемость сотрудника за период
+type EmployeeAttendance struct {
+	EmployeeID    uint     `json:"employee_id"`
+	EmployeeName  string   `json:"employee_name"`
+	PlannedShifts int      `json:"planned_shifts"`
+	PlannedHours  float64  `json:"planned_hours"`
+	WorkedHours   float64  `json:"worked_hours"` // по завершенным отметкам
+	DaysPresent   int      `json:"days_present"`
+	MissedShifts  int      `json:"missed_shifts"` // прошедшие смены без отметки о приходе
+	LateShifts    int      `json:"late_shifts"`
+	MissedDates   []string `json:"missed_dates"` // дни пропущенных смен, YYYY-MM-DD
+}
+
+// collectAttendance сводит смены и отметки за полуинтервал [start, end) по сотрудникам.
+// В результате есть все сотрудники, у которых были смены или отметки.
+func collectAttendance(shiftRepo *repository.ShiftRepository, start, end time.Time) (map[uint]*EmployeeAttendance, error) {
+	shifts, err := shiftRepo.FindShifts(repository.ShiftFilter{Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+
+	// на смену в начале периода могли отметиться чуть раньше его начала
+	entries, err := shiftRepo.GetTimeEntries(start.Add(-clockInEarly), end)
+	if err != nil {
+		return nil, err
+	}
+
+	attendance := make(map[uint]*EmployeeAttendance)
+	get := func(employeeID uint) *EmployeeAttendance {
+		if attendance[employeeID] == nil {
+			attendance[employeeID] = &EmployeeAttendance{EmployeeID: employeeID, MissedDates: []string{}}
+		}
+		return attendance[employeeID]
+	}
+
+	// первый приход на каждую смену
+	firstClockIn := make(map[uint]time.Time)
+	presentDays := make(map[uint]map[string]bool)
+	for _, entry := range entries {
+		if entry.ShiftID != nil {
+			if first, ok := firstClockIn[*entry.ShiftID]; !ok || entry.ClockInAt.Before(first) {
+				firstClockIn[*entry.ShiftID] = entry.ClockInAt
+			}
+		}
+
+		if entry.ClockInAt.Before(start) {
+			continue
+		}
+		employee := get(entry.EmployeeID)
+
+		if entry.ClockOutAt != nil {
+			employee.WorkedHours += entry.ClockOutAt.Sub(entry.ClockInAt).Hours()
+		}
+
+		if presentDays[entry.EmployeeID] == nil {
+			presentDays[entry.EmployeeID] = make(map[string]bool)
+		}
+		presentDays[entry.EmployeeID][entry.ClockInAt.UTC().Format(dateLayout)] = true
+	}
+
+	now := time.Now()
+	for _, shift := range shifts {
+		employee := get(shift.EmployeeID)
+		employee.PlannedShifts++
+		employee.PlannedHours += shift.EndsAt.Sub(shift.StartsAt).Hours()
+
+		first, ok := firstClockIn[shift.ID]
+		switch {
+		case !ok && shift.EndsAt.Before(now):
+			employee.MissedShifts++
+			employee.MissedDates = append(employee.MissedDates, shift.StartsAt.UTC().Format(dateLayout))
+		case ok && first.After(shift.StartsAt.Add(lateGrace)):
+			employee.LateShifts++
+		}
+	}
+
+	for employeeID, employee := range attendance {
+		employee.DaysPresent = len(presentDays[employeeID])
+		employee.PlannedHours = roundHours(employee.PlannedHours)
+		employee.WorkedHours = roundHours(employee.WorkedHours)
+		sort.Strings(employee.MissedDates)
+	}
+
+	return attendance, nil
+}
+
+func roundHours(hours float64) float64 {
+	return math.Round(hours*100) / 100
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type UserService struct {
+	userRepo     *repository.UserRepository
+	employeeRepo *repository.EmployeeRepository
+}
+
+func NewUserService(
+	userRepo *repository.UserRepository,
+	employeeRepo *repository.EmployeeRepository,
+) *UserService {
+	return &UserService{
+		userRepo:     userRepo,
+		employeeRepo: employeeRepo,
+	}
+}
+
+func (s *UserService) GetAllUsers() ([]model.User, error) {
+	return s.userRepo.GetAll()
+}
+
+func (s *UserService) GetUserByID(id uint) (*model.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, notFoundOr(err, ErrUserNotFound)
+	}
+	return user, nil
+}
+
+func (s *UserService) CreateUser(user *model.User, password string) error {
+	if err := s.validateUser(user); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+
+	return s.userRepo.Create(user)
+}
+
+// UpdateUser меняет логин, роль и сотрудника; пароль меняется, только если он передан
+func (s *UserService) UpdateUser(user *model.User, password string) error {
+	oldUser, err := s.userRepo.GetByID(user.ID)
+	if err != nil {
+		return notFoundOr(err, ErrUserNotFound)
+	}
+
+	if err := s.validateUser(user); err != nil {
+		return err
+	}
+
+	if oldUser.Role == model.RoleAdmin && user.Role != model.RoleAdmin {
+		if err := s.checkNotLastAdmin(); err != nil {
+			return err
+		}
+	}
+
+	user.PasswordHash = oldUser.PasswordHash
+	if password != "" {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hash
+	}
+	user.CreatedAt = oldUser.CreatedAt
+
+	return s.userRepo.Update(user, password != "")
+}
+
+func (s *UserService) DeleteUser(id uint) error {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return notFoundOr(err, ErrUserNotFound)
+	}
+
+	if user.Role == model.RoleAdmin {
+		if err := s.checkNotLastAdmin(); err != nil {
+			return err
+		}
+	}
+
+	return s.userRepo.Delete(id)
+}
+
+func (s *UserService) validateUser(user *model.User) error {
+	switch user.Role {
+	case model.RoleAdmin, model.RoleManager:
+	case model.RoleWorker:
+		if user.EmployeeID == nil {
+			return ErrWorkerWithoutEmployee
+		}
+	default:
+		return ErrUnknownRole
+	}
+
+	if user.EmployeeID != nil {
+		if _, err := s.employeeRepo.GetByID(*user.EmployeeID); err != nil {
+			return notFoundOr(err, ErrEmployeeNotFound)
+		}
+	}
+
+	existing, err := s.userRepo.GetByUsername(user.Username)
+	if err == nil && existing.ID != user.ID {
+		return ErrUsernameTaken
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserService) checkNotLastAdmin() error {
+	users, err := s.userRepo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	admins := 0
+	for _, user := range users {
+		if user.Role == model.RoleAdmin {
+			admins++
+		}
+	}
+
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"chicken-farm/internal/model"
+	"chicken-farm/internal/repository"
+)
+
+// WorkloadService распределяет клетки между сотрудниками так, чтобы число кур у них было как можно ровнее
+type WorkloadService struct {
+	employeeRepo *repository.EmployeeRepository
+	farmRepo     *repository.FarmRepository
+}
+
+func NewWorkloadService(
+	employeeRepo *repository.EmployeeRepository,
+	farmRepo *repository.FarmRepository,
+) *WorkloadService {
+	return &WorkloadService{
+		employeeRepo: employeeRepo,
+		farmRepo:     farmRepo,
+	}
+}
+
+// CageAssignmentChange - клетка, у которой меняется сотрудник
+type CageAssignmentChange struct {
+	CageID          uint   `json:"cage_id"`
+	CageNumber      int    `json:"cage_number"`
+	Row             string `json:"row"`
+	FromEmployeeIDs []uint `json:"from_employee_ids"`
+	ToEmployeeID    uint   `json:"to_employee_id"`
+}
+
+type EmployeeLoad struct {
+	EmployeeID     uint   `json:"employee_id"`
+	EmployeeName   string `json:"employee_name"`
+	MaxChickens    int    `json:"max_chickens"`
+	ChickensBefore int    `json:"chickens_before"`
+	ChickensAfter  int    `json:"chickens_after"`
+}
+
+// BalancePlan - предложенное распределение и его отличие от текущего
+type BalancePlan struct {
+	Applied      bool                   `json:"applied"`
+	Changes      []CageAssignmentChange `json:"changes"`
+	Loads        []EmployeeLoad         `json:"loads"`
+	SpreadBefore int                    `json:"spread_before"` // разница между самой большой и самой малой нагрузкой
+	SpreadAfter  int                    `json:"spread_after"`
+}
+
+// cageGroup - клетки, которые должны достаться одному сотруднику: весь ряд или отдельная клетка без ряда
+type cageGroup struct {
+	name     string
+	cages    []model.Cage
+	chickens int
+}
+
+// Balance строит распределение клеток: каждая клетка получает одного сотрудника, ряд целиком
+// достается одному человеку, нагрузка не превышает max_chickens. Группы раздаются от самых
+// тяжелых к легким тому, у кого сейчас меньше всего кур; при равенстве клетка остается у
+// прежнего сотрудника. Если dryRun = false, распределение сохраняется с сегодняшнего дня.
+func (s *WorkloadService) Balance(dryRun bool) (*BalancePlan, error) {
+	employees, err := s.employeeRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(employees) == 0 {
+		return nil, conflictError("no_employees", "there are no employees to assign cages to")
+	}
+	sort.Slice(employees, func(i, j int) bool {
+		return employees[i].ID < employees[j].ID
+	})
+
+	cages, err := s.farmRepo.GetAllCages()
+	if err != nil {
+		return nil, err
+	}
+
+	occupancy, err := s.farmRepo.GetCageOccupancy()
+	if err != nil {
+		return nil, err
+	}
+
+	loadsBefore, err := s.employeeRepo.GetAllEmployeeChickenCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	cageIDs := make([]uint, len(cages))
+	for i, cage := range cages {
+		cageIDs[i] = cage.ID
+	}
+	caretakers, err := s.employeeRepo.GetCaretakerIDs(cageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := groupCagesByRow(cages, occupancy)
+
+	loads := make(map[uint]int, len(employees))
+	target := make(map[uint]uint, len(cages))
+	for _, group := range groups {
+		employeeID, ok := pickEmployee(employees, loads, group, caretakers)
+		if !ok {
+			return nil, conflictError("max_load_exceeded",
+				fmt.Sprintf("no employee can take %s with %d chickens without exceeding max_chickens", group.name, group.chickens))
+		}
+
+		loads[employeeID] += group.chickens
+		for _, cage := range group.cages {
+			target[cage.ID] = employeeID
+		}
+	}
+
+	plan := &BalancePlan{
+		Changes: make([]CageAssignmentChange, 0),
+		Loads:   make([]EmployeeLoad, 0, len(employees)),
+	}
+
+	var moves []repository.CageMove
+	for _, cage := range cages {
+		current := caretakers[cage.ID]
+		if len(current) == 1 && current[0] == target[cage.ID] {
+			continue
+		}
+
+		from := make([]uint, 0, len(current))
+		for _, employeeID := range current {
+			if employeeID != target[cage.ID] {
+				from = append(from, employeeID)
+			}
+		}
+
+		plan.Changes = append(plan.Changes, CageAssignmentChange{
+			CageID:          cage.ID,
+			CageNumber:      cage.Number,
+			Row:             cage.Row,
+			FromEmployeeIDs: from,
+			ToEmployeeID:    target[cage.ID],
+		})
+		moves = append(moves, repository.CageMove{CageID: cage.ID, FromEmployeeIDs: from, ToEmployeeID: target[cage.ID]})
+	}
+
+	before := make([]int, 0, len(employees))
+	after := make([]int, 0, len(employees))
+	for _, employee := range employees {
+		plan.Loads = append(plan.Loads, EmployeeLoad{
+			EmployeeID:     employee.ID,
+			EmployeeName:   employee.FullName,
+			MaxChickens:    employee.MaxChickens,
+			ChickensBefore: loadsBefore[employee.ID],
+			ChickensAfter:  loads[employee.ID],
+		})
+		before = append(before, loadsBefore[employee.ID])
+		after = append(after, loads[employee.ID])
+	}
+	plan.SpreadBefore = slices.Max(before) - slices.Min(before)
+	plan.SpreadAfter = slices.Max(after) - slices.Min(after)
+
+	if dryRun || len(moves) == 0 {
+		return plan, nil
+	}
+
+	exclusive, err := exclusiveAssignment(s.farmRepo)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.employeeRepo.ReassignCages(moves, truncateToDay(time.Now()), exclusive); err != nil {
+		return nil, cageTakenOr(err)
+	}
+	plan.Applied = true
+
+	return plan, nil
+}
+
+// groupCagesByRow объединяет клетки одного ряда и сортирует группы от самых тяжелых к легким
+func groupCagesByRow(cages []model.Cage, occupancy map[uint]int) []cageGroup {
+	var groups []cageGroup
+	rows := make(map[string]int)
+
+	for _, cage := range cages {
+		index, ok := rows[cage.Row]
+		if cage.Row == "" || !ok {
+			name := fmt.Sprintf("cage %d", cage.Number)
+			if cage.Row != "" {
+				name = fmt.Sprintf("row %q", cage.Row)
+				rows[cage.Row] = len(groups)
+			}
+			groups = append(groups, cageGroup{name: name})
+			index = len(groups) - 1
+		}
+
+		groups[index].cages = append(groups[index].cages, cage)
+		groups[index].chickens += occupancy[cage.ID]
+	}
+
+	// клетки уже упорядочены по номеру, поэтому при равном весе порядок групп стабилен
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].chickens > groups[j].chickens
+	})
+
+	return groups
+}
+
+// pickEmployee выбирает для группы наименее загруженного сотрудника, которому она не превысит
+// предельную нагрузку. При равной нагрузке предпочитается тот, кто уже обслуживает больше клеток группы.
+func pickEmployee(employees []model.Employee, loads map[uint]int, group cageGroup, caretakers map[uint][]uint) (uint, bool) {
+	var best uint
+	bestLoad, bestKept := 0, 0
+	found := false
+
+	for _, employee := range employees {
+		load := loads[employee.ID]
+		if employee.MaxChickens > 0 && load+group.chickens > employee.MaxChickens {
+			continue
+		}
+
+		kept := 0
+		for _, cage := range group.cages {
+			if slices.Contains(caretakers[cage.ID], employee.ID) {
+				kept++
+			}
+		}
+
+		if !found || load < bestLoad || (load == bestLoad && kept > bestKept) {
+			best, bestLoad, bestKept, found = employee.ID, load, kept, true
+		}
+	}
+
+	return best, found
+}
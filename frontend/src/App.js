@@ -1,12 +1,37 @@
-import React from 'react';
-import { BrowserRouter as Router, Routes, Route, Link } from 'react-router-dom';
+import React, { useState, useEffect } from 'react';
+import { BrowserRouter as Router, Routes, Route, Link, Navigate } from 'react-router-dom';
 import ChickenManagement from './components/ChickenManagement';
 import EmployeeManagement from './components/EmployeeManagement';
 import Reports from './components/Reports';
 import Dashboard from './components/Dashboard';
+import Login from './components/Login';
+import api, { getToken, setToken } from './api';
 import './App.css';
 
+// Без токена сессии все страницы, кроме входа, отправляют на /login
+const RequireAuth = ({ children }) => (getToken() ? children : <Navigate to="/login" replace />);
+
 function App() {
+  const [user, setUser] = useState(null);
+
+  useEffect(() => {
+    if (getToken()) {
+      api.get('/api/auth/me')
+        .then((response) => setUser(response.data))
+        .catch((err) => console.log('Ошибка получения пользователя:', err));
+    }
+  }, []);
+
+  const handleLogout = async () => {
+    try {
+      await api.post('/api/auth/logout');
+    } catch (err) {
+      console.log('Ошибка выхода:', err);
+    }
+    setToken(null);
+    setUser(null);
+  };
+
   return (
     <Router>
       <div className="App">
@@ -19,6 +44,12 @@ function App() {
               <Link to="/chickens" className="nav-link">Куры</Link>
               <Link to="/employees" className="nav-link">Работники</Link>
               <Link to="/reports" className="nav-link">Отчеты</Link>
+              {user && (
+                <div className="nav-user">
+                  <span>{user.username}</span>
+                  <Link to="/login" className="nav-link" onClick={handleLogout}>Выйти</Link>
+                </div>
+              )}
             </nav>
           </div>
         </header>
@@ -27,10 +58,11 @@ function App() {
         <main className="main-content">
           <div className="container">
             <Routes>
-              <Route path="/" element={<Dashboard />} />
-              <Route path="/chickens" element={<ChickenManagement />} />
-              <Route path="/employees" element={<EmployeeManagement />} />
-              <Route path="/reports" element={<Reports />} />
+              <Route path="/login" element={<Login onLogin={setUser} />} />
+              <Route path="/" element={<RequireAuth><Dashboard /></RequireAuth>} />
+              <Route path="/chickens" element={<RequireAuth><ChickenManagement /></RequireAuth>} />
+              <Route path="/employees" element={<RequireAuth><EmployeeManagement /></RequireAuth>} />
+              <Route path="/reports" element={<RequireAuth><Reports /></RequireAuth>} />
             </Routes>
           </div>
         </main>
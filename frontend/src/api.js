@@ -0,0 +1,44 @@
+import axios from 'axios';
+
+const TOKEN_KEY = 'token';
+
+export const getToken = () => localStorage.getItem(TOKEN_KEY);
+
+export const setToken = (token) => {
+  if (token) {
+    localStorage.setItem(TOKEN_KEY, token);
+  } else {
+    localStorage.removeItem(TOKEN_KEY);
+  }
+};
+
+// Общий клиент для всех страниц: добавляет токен сессии к каждому запросу
+const api = axios.create({
+  baseURL: 'http://localhost:8080',
+  headers: {
+    'Content-Type': 'application/json',
+  },
+});
+
+api.interceptors.request.use((config) => {
+  const token = getToken();
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+  return config;
+});
+
+// Истекший или отозванный токен - возвращаем на страницу входа
+api.interceptors.response.use(
+  (response) => response,
+  (error) => {
+    const isLogin = error.config && error.config.url === '/api/auth/login';
+    if (error.response && error.response.status === 401 && !isLogin) {
+      setToken(null);
+      window.location.assign('/login');
+    }
+    return Promise.reject(error);
+  }
+);
+
+export default api;
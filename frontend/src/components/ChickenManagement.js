@@ -1,13 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import axios from 'axios';
-
-// Настройка базового URL для axios
-const api = axios.create({
-  baseURL: 'http://localhost:8080',
-  headers: {
-    'Content-Type': 'application/json',
-  },
-});
+import api from '../api';
 
 const ChickenManagement = () => {
   const [chickens, setChickens] = useState([]);
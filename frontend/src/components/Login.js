@@ -0,0 +1,81 @@
+import React, { useState } from 'react';
+import { useNavigate } from 'react-router-dom';
+import api, { setToken } from '../api';
+
+const Login = ({ onLogin }) => {
+  const navigate = useNavigate();
+  const [formData, setFormData] = useState({ username: '', password: '' });
+  const [error, setError] = useState(null);
+  const [submitting, setSubmitting] = useState(false);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    try {
+      setSubmitting(true);
+      setError(null);
+      const response = await api.post('/api/auth/login', formData);
+      setToken(response.data.token);
+      onLogin(response.data.user);
+      navigate('/');
+    } catch (err) {
+      if (err.response?.status === 401) {
+        setError('Неверный логин или пароль');
+      } else {
+        setError(err.response?.data?.error || 'Ошибка входа');
+      }
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleInputChange = (e) => {
+    setFormData({
+      ...formData,
+      [e.target.name]: e.target.value
+    });
+  };
+
+  return (
+    <div className="page login-page">
+      <h1 className="page-title">Вход</h1>
+
+      {error && <div className="error">{error}</div>}
+
+      <form onSubmit={handleSubmit}>
+        <div className="form-group">
+          <label className="form-label">Логин:</label>
+          <input
+            type="text"
+            name="username"
+            className="form-input"
+            value={formData.username}
+            onChange={handleInputChange}
+            autoComplete="username"
+            required
+          />
+        </div>
+
+        <div className="form-group">
+          <label className="form-label">Пароль:</label>
+          <input
+            type="password"
+            name="password"
+            className="form-input"
+            value={formData.password}
+            onChange={handleInputChange}
+            autoComplete="current-password"
+            required
+          />
+        </div>
+
+        <div className="form-actions">
+          <button type="submit" className="btn btn-primary" disabled={submitting}>
+            Войти
+          </button>
+        </div>
+      </form>
+    </div>
+  );
+};
+
+export default Login;
@@ -7,6 +7,7 @@ test.describe('Управление курами', () => {
 
   test.beforeEach(async ({ page }) => {
     helpers = new TestHelpers(page);
+    await helpers.login();
     await page.goto('/chickens');
     await helpers.waitForPageLoad();
   });
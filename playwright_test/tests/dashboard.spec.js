@@ -6,6 +6,7 @@ test.describe('Dashboard Page', () => {
 
   test.beforeEach(async ({ page }) => {
     helpers = new TestHelpers(page);
+    await helpers.login();
     await page.goto('/');
     await helpers.waitForPageLoad();
   });
@@ -3,6 +3,21 @@ export class TestHelpers {
     this.page = page;
   }
 
+  // Получает токен у API и кладет его в localStorage до загрузки страницы
+  async login(
+    username = process.env.E2E_USERNAME || 'admin',
+    password = process.env.E2E_PASSWORD || 'change-me'
+  ) {
+    const response = await this.page.request.post('http://localhost:8080/api/auth/login', {
+      data: { username, password }
+    });
+    if (!response.ok()) {
+      throw new Error(`Не удалось войти как ${username}: ${response.status()}`);
+    }
+    const { token } = await response.json();
+    await this.page.addInitScript((value) => localStorage.setItem('token', value), token);
+  }
+
   async waitForPageLoad() {
     await this.page.waitForLoadState('networkidle');
     await this.page.waitForTimeout(500);